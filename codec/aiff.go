@@ -0,0 +1,267 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+// aiffDecoder 是纯 Go 实现的 AIFF / AIFC 解码器，支持未压缩 PCM（大端和 sowt 小端）以及 32/64 位浮点
+type aiffDecoder struct {
+	rc          io.ReadSeekCloser
+	format      beep.Format
+	channels    int
+	sampleBytes int
+	frames      int
+	dataStart   int64
+	pos         int
+	decode      func(b []byte) float64
+	buf         []byte
+	err         error
+}
+
+// DecodeAIFF 解码 AIFF / AIFC 文件
+func DecodeAIFF(rc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	d := &aiffDecoder{rc: rc}
+	if err := d.readHeader(); err != nil {
+		return nil, beep.Format{}, fmt.Errorf("aiff: %w", err)
+	}
+	return d, d.format, nil
+}
+
+func (d *aiffDecoder) readHeader() error {
+	var form [12]byte
+	if _, err := io.ReadFull(d.rc, form[:]); err != nil {
+		return err
+	}
+	if string(form[0:4]) != "FORM" {
+		return errors.New("missing FORM header")
+	}
+	isAIFC := false
+	switch string(form[8:12]) {
+	case "AIFF":
+	case "AIFC":
+		isAIFC = true
+	default:
+		return errors.New("not an AIFF file")
+	}
+
+	compression := "NONE"
+	bits := 0
+	haveComm, haveData := false, false
+	for !(haveComm && haveData) {
+		var header [8]byte
+		if _, err := io.ReadFull(d.rc, header[:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return err
+		}
+		id := string(header[0:4])
+		size := int64(binary.BigEndian.Uint32(header[4:8]))
+		// 块长度为奇数时后面有一个填充字节
+		padded := size + size%2
+
+		switch id {
+		case "COMM":
+			comm := make([]byte, size)
+			if _, err := io.ReadFull(d.rc, comm); err != nil {
+				return err
+			}
+			if len(comm) < 18 {
+				return errors.New("COMM chunk too short")
+			}
+			d.channels = int(binary.BigEndian.Uint16(comm[0:2]))
+			d.frames = int(binary.BigEndian.Uint32(comm[2:6]))
+			bits = int(binary.BigEndian.Uint16(comm[6:8]))
+			d.format.SampleRate = beep.SampleRate(extendedToFloat(comm[8:18]))
+			if isAIFC {
+				if len(comm) < 22 {
+					return errors.New("AIFC COMM chunk too short")
+				}
+				compression = string(comm[18:22])
+			}
+			if size%2 == 1 {
+				if _, err := d.rc.Seek(1, io.SeekCurrent); err != nil {
+					return err
+				}
+			}
+			haveComm = true
+		case "SSND":
+			var ssnd [8]byte
+			if _, err := io.ReadFull(d.rc, ssnd[:]); err != nil {
+				return err
+			}
+			offset := int64(binary.BigEndian.Uint32(ssnd[0:4]))
+			start, err := d.rc.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+			d.dataStart = start + offset
+			haveData = true
+			if !haveComm {
+				// COMM 在 SSND 之后，先跳过声音数据继续找
+				if _, err := d.rc.Seek(start+padded-8, io.SeekStart); err != nil {
+					return err
+				}
+			}
+		default:
+			if _, err := d.rc.Seek(padded, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+	}
+	if !haveComm || !haveData {
+		return errors.New("missing COMM or SSND chunk")
+	}
+	if d.channels < 1 || d.format.SampleRate <= 0 {
+		return errors.New("invalid COMM chunk")
+	}
+
+	d.sampleBytes = (bits + 7) / 8
+	switch compression {
+	case "NONE", "twos":
+		d.decode = pcmDecoder(d.sampleBytes, true)
+	case "sowt":
+		d.decode = pcmDecoder(d.sampleBytes, false)
+	case "fl32", "FL32":
+		d.sampleBytes = 4
+		d.decode = func(b []byte) float64 {
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+		}
+	case "fl64", "FL64":
+		d.sampleBytes = 8
+		d.decode = func(b []byte) float64 {
+			return math.Float64frombits(binary.BigEndian.Uint64(b))
+		}
+	default:
+		return fmt.Errorf("unsupported AIFC compression %q", compression)
+	}
+	if d.decode == nil {
+		return fmt.Errorf("unsupported sample size %d", bits)
+	}
+
+	d.format.NumChannels = 2
+	if d.channels == 1 {
+		d.format.NumChannels = 1
+	}
+	d.format.Precision = d.sampleBytes
+	if d.format.Precision > 3 {
+		d.format.Precision = 3
+	}
+	_, err := d.rc.Seek(d.dataStart, io.SeekStart)
+	return err
+}
+
+// pcmDecoder 返回把有符号整数采样转换到 [-1, 1] 的函数
+func pcmDecoder(size int, bigEndian bool) func(b []byte) float64 {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	switch size {
+	case 1:
+		return func(b []byte) float64 {
+			return float64(int8(b[0])) / (1 << 7)
+		}
+	case 2:
+		return func(b []byte) float64 {
+			return float64(int16(order.Uint16(b))) / (1 << 15)
+		}
+	case 3:
+		return func(b []byte) float64 {
+			hi, lo := b[0], b[2]
+			if !bigEndian {
+				hi, lo = lo, hi
+			}
+			v := int32(hi)<<24 | int32(b[1])<<16 | int32(lo)<<8
+			return float64(v) / (1 << 31)
+		}
+	case 4:
+		return func(b []byte) float64 {
+			return float64(int32(order.Uint32(b))) / (1 << 31)
+		}
+	}
+	return nil
+}
+
+// extendedToFloat 把 80 位 IEEE 754 扩展精度浮点数（AIFF 的采样率字段）转换成 float64
+func extendedToFloat(b []byte) float64 {
+	sign := 1.0
+	if b[0]&0x80 != 0 {
+		sign = -1
+	}
+	exp := int(binary.BigEndian.Uint16(b[0:2]) & 0x7fff)
+	mant := binary.BigEndian.Uint64(b[2:10])
+	if exp == 0 && mant == 0 {
+		return 0
+	}
+	return sign * math.Ldexp(float64(mant), exp-16383-63)
+}
+
+func (d *aiffDecoder) Stream(samples [][2]float64) (n int, ok bool) {
+	if d.err != nil || d.pos >= d.frames {
+		return 0, false
+	}
+	frameSize := d.channels * d.sampleBytes
+	want := len(samples)
+	if left := d.frames - d.pos; want > left {
+		want = left
+	}
+	if cap(d.buf) < want*frameSize {
+		d.buf = make([]byte, want*frameSize)
+	}
+	buf := d.buf[:want*frameSize]
+	read, err := io.ReadFull(d.rc, buf)
+	frames := read / frameSize
+	for i := 0; i < frames; i++ {
+		frame := buf[i*frameSize:]
+		left := d.decode(frame[:d.sampleBytes])
+		right := left
+		if d.channels > 1 {
+			right = d.decode(frame[d.sampleBytes : 2*d.sampleBytes])
+		}
+		samples[i] = [2]float64{left, right}
+	}
+	d.pos += frames
+	if err != nil {
+		// 文件比 COMM 里声明的短，按实际长度结束
+		d.frames = d.pos
+		if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			d.err = err
+		}
+	}
+	return frames, frames > 0
+}
+
+func (d *aiffDecoder) Err() error {
+	return d.err
+}
+
+func (d *aiffDecoder) Len() int {
+	return d.frames
+}
+
+func (d *aiffDecoder) Position() int {
+	return d.pos
+}
+
+func (d *aiffDecoder) Seek(p int) error {
+	if p < 0 || p > d.frames {
+		return fmt.Errorf("aiff: seek position %d out of range [0, %d]", p, d.frames)
+	}
+	frameSize := int64(d.channels * d.sampleBytes)
+	if _, err := d.rc.Seek(d.dataStart+int64(p)*frameSize, io.SeekStart); err != nil {
+		return err
+	}
+	d.pos = p
+	return nil
+}
+
+func (d *aiffDecoder) Close() error {
+	return d.rc.Close()
+}
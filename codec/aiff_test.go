@@ -0,0 +1,341 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// memFile 是内存中的 io.ReadSeekCloser
+type memFile struct {
+	*bytes.Reader
+}
+
+func (memFile) Close() error { return nil }
+
+func openMem(data []byte) memFile {
+	return memFile{bytes.NewReader(data)}
+}
+
+// extended 把采样率编码成 80 位扩展精度浮点数
+func extended(f float64) []byte {
+	b := make([]byte, 10)
+	if f == 0 {
+		return b
+	}
+	exp := math.Ilogb(f)
+	binary.BigEndian.PutUint16(b[0:2], uint16(exp+16383))
+	binary.BigEndian.PutUint64(b[2:10], uint64(math.Ldexp(f, 63-exp)))
+	return b
+}
+
+func chunk(id string, data []byte) []byte {
+	b := append([]byte(id), 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[4:8], uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// aiffFile 描述要生成的测试文件
+type aiffFile struct {
+	aifc        bool
+	compression string // AIFC 的压缩类型
+	channels    int
+	bits        int
+	rate        float64
+	frames      int // COMM 中声明的帧数，0 表示按数据计算
+	data        []byte
+	offset      int  // SSND 的 offset 字段，数据前面的空白
+	commLast    bool // COMM 放在 SSND 之后
+	extra       bool // 在最前面插入一个奇数长度的未知块
+}
+
+func (f aiffFile) bytes() []byte {
+	frames := f.frames
+	if frames == 0 {
+		frames = len(f.data) / (f.channels * ((f.bits + 7) / 8))
+	}
+	comm := binary.BigEndian.AppendUint16(nil, uint16(f.channels))
+	comm = binary.BigEndian.AppendUint32(comm, uint32(frames))
+	comm = binary.BigEndian.AppendUint16(comm, uint16(f.bits))
+	comm = append(comm, extended(f.rate)...)
+	if f.aifc {
+		// 压缩名称是 pascal 字符串，这里故意让 COMM 的长度是奇数
+		name := "not compressed"
+		comm = append(comm, f.compression...)
+		comm = append(comm, byte(len(name)))
+		comm = append(comm, name...)
+	}
+	ssnd := binary.BigEndian.AppendUint32(nil, uint32(f.offset))
+	ssnd = binary.BigEndian.AppendUint32(ssnd, 0)
+	ssnd = append(ssnd, make([]byte, f.offset)...)
+	ssnd = append(ssnd, f.data...)
+
+	var body []byte
+	if f.extra {
+		body = append(body, chunk("NAME", []byte("abc"))...)
+	}
+	if f.commLast {
+		body = append(body, chunk("SSND", ssnd)...)
+		body = append(body, chunk("COMM", comm)...)
+	} else {
+		body = append(body, chunk("COMM", comm)...)
+		body = append(body, chunk("SSND", ssnd)...)
+	}
+	kind := "AIFF"
+	if f.aifc {
+		kind = "AIFC"
+	}
+	return chunk("FORM", append([]byte(kind), body...))
+}
+
+// pcm 按指定的字节序把整数采样写成 bits 位 PCM
+func pcm(bits int, bigEndian bool, samples ...int32) []byte {
+	var out []byte
+	size := bits / 8
+	for _, s := range samples {
+		b := make([]byte, size)
+		for i := 0; i < size; i++ {
+			shift := 8 * (size - 1 - i)
+			if !bigEndian {
+				shift = 8 * i
+			}
+			b[i] = byte(s >> shift)
+		}
+		out = append(out, b...)
+	}
+	return out
+}
+
+func floats32(samples ...float32) []byte {
+	var out []byte
+	for _, s := range samples {
+		out = binary.BigEndian.AppendUint32(out, math.Float32bits(s))
+	}
+	return out
+}
+
+func floats64(samples ...float64) []byte {
+	var out []byte
+	for _, s := range samples {
+		out = binary.BigEndian.AppendUint64(out, math.Float64bits(s))
+	}
+	return out
+}
+
+func TestDecodeAIFF(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      aiffFile
+		rate      int
+		channels  int
+		precision int
+		want      [][2]float64
+	}{
+		{
+			name: "16 bit stereo",
+			file: aiffFile{channels: 2, bits: 16, rate: 44100, data: pcm(16, true, 16384, -16384, 32767, -32768)},
+			rate: 44100, channels: 2, precision: 2,
+			want: [][2]float64{{0.5, -0.5}, {32767.0 / 32768, -1}},
+		},
+		{
+			name: "8 bit mono",
+			file: aiffFile{channels: 1, bits: 8, rate: 8000, data: pcm(8, true, 64, -128, 0)},
+			rate: 8000, channels: 1, precision: 1,
+			want: [][2]float64{{0.5, 0.5}, {-1, -1}, {0, 0}},
+		},
+		{
+			name: "24 bit",
+			file: aiffFile{channels: 2, bits: 24, rate: 96000, data: pcm(24, true, 1<<22, -(1 << 22), 1, -1)},
+			rate: 96000, channels: 2, precision: 3,
+			want: [][2]float64{{0.5, -0.5}, {1.0 / (1 << 23), -1.0 / (1 << 23)}},
+		},
+		{
+			name: "32 bit",
+			file: aiffFile{channels: 2, bits: 32, rate: 192000, data: pcm(32, true, 1<<30, -(1 << 31))},
+			rate: 192000, channels: 2, precision: 3,
+			want: [][2]float64{{0.5, -1}},
+		},
+		{
+			name: "20 bit in 3 bytes",
+			file: aiffFile{channels: 1, bits: 20, rate: 48000, data: pcm(24, true, 1<<22)},
+			rate: 48000, channels: 1, precision: 3,
+			want: [][2]float64{{0.5, 0.5}},
+		},
+		{
+			// 非整数采样率也能从扩展精度字段中读出来
+			name: "fractional rate",
+			file: aiffFile{channels: 2, bits: 16, rate: 22050.5, data: pcm(16, true, 0, 0)},
+			rate: 22050, channels: 2, precision: 2,
+			want: [][2]float64{{0, 0}},
+		},
+		{
+			name: "AIFC big endian",
+			file: aiffFile{aifc: true, compression: "NONE", channels: 2, bits: 16, rate: 44100, data: pcm(16, true, 8192, -8192)},
+			rate: 44100, channels: 2, precision: 2,
+			want: [][2]float64{{0.25, -0.25}},
+		},
+		{
+			name: "AIFC twos",
+			file: aiffFile{aifc: true, compression: "twos", channels: 1, bits: 16, rate: 44100, data: pcm(16, true, -8192)},
+			rate: 44100, channels: 1, precision: 2,
+			want: [][2]float64{{-0.25, -0.25}},
+		},
+		{
+			name: "AIFC sowt 16 bit",
+			file: aiffFile{aifc: true, compression: "sowt", channels: 2, bits: 16, rate: 44100, data: pcm(16, false, 16384, -32768, 1, -1)},
+			rate: 44100, channels: 2, precision: 2,
+			want: [][2]float64{{0.5, -1}, {1.0 / 32768, -1.0 / 32768}},
+		},
+		{
+			name: "AIFC sowt 24 bit",
+			file: aiffFile{aifc: true, compression: "sowt", channels: 2, bits: 24, rate: 48000, data: pcm(24, false, 1<<22, -(1 << 23))},
+			rate: 48000, channels: 2, precision: 3,
+			want: [][2]float64{{0.5, -1}},
+		},
+		{
+			name: "AIFC sowt 32 bit",
+			file: aiffFile{aifc: true, compression: "sowt", channels: 1, bits: 32, rate: 48000, data: pcm(32, false, -(1 << 30))},
+			rate: 48000, channels: 1, precision: 3,
+			want: [][2]float64{{-0.5, -0.5}},
+		},
+		{
+			name: "AIFC fl32",
+			file: aiffFile{aifc: true, compression: "fl32", channels: 2, bits: 32, rate: 44100, data: floats32(0.125, -0.75)},
+			rate: 44100, channels: 2, precision: 3,
+			want: [][2]float64{{0.125, -0.75}},
+		},
+		{
+			name: "AIFC FL64",
+			file: aiffFile{aifc: true, compression: "FL64", channels: 1, bits: 64, rate: 44100, data: floats64(0.1, -0.2)},
+			rate: 44100, channels: 1, precision: 3,
+			want: [][2]float64{{0.1, 0.1}, {-0.2, -0.2}},
+		},
+		{
+			// 奇数长度的块后面有填充字节，COMM 可以在 SSND 之后，SSND 可以有 offset
+			name: "padding, offset and chunk order",
+			file: aiffFile{aifc: true, compression: "NONE", channels: 1, bits: 16, rate: 44100, offset: 6, commLast: true, extra: true,
+				data: pcm(16, true, 16384, -16384, 8192)},
+			rate: 44100, channels: 1, precision: 2,
+			want: [][2]float64{{0.5, 0.5}, {-0.5, -0.5}, {0.25, 0.25}},
+		},
+		{
+			// 多于两个声道时只取前两个
+			name: "four channels",
+			file: aiffFile{channels: 4, bits: 16, rate: 44100, data: pcm(16, true, 16384, -16384, 32767, 32767)},
+			rate: 44100, channels: 2, precision: 2,
+			want: [][2]float64{{0.5, -0.5}},
+		},
+		{
+			// 数据比 COMM 中声明的短时按实际长度结束
+			name: "truncated data",
+			file: aiffFile{channels: 1, bits: 16, rate: 44100, frames: 10, data: pcm(16, true, 16384, 8192)},
+			rate: 44100, channels: 1, precision: 2,
+			want: [][2]float64{{0.5, 0.5}, {0.25, 0.25}},
+		},
+	}
+	for _, tt := range tests {
+		s, format, err := DecodeAIFF(openMem(tt.file.bytes()))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if int(format.SampleRate) != tt.rate || format.NumChannels != tt.channels || format.Precision != tt.precision {
+			t.Errorf("%s: format %+v, want %d Hz %d channels precision %d", tt.name, format, tt.rate, tt.channels, tt.precision)
+		}
+		// 每次只读一帧，检查跨越读取边界的情况
+		var got [][2]float64
+		buf := make([][2]float64, 1)
+		for {
+			n, ok := s.Stream(buf)
+			got = append(got, buf[:n]...)
+			if !ok {
+				break
+			}
+		}
+		if err := s.Err(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: decoded %d frames, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i][0]-tt.want[i][0]) > 1e-7 || math.Abs(got[i][1]-tt.want[i][1]) > 1e-7 {
+				t.Errorf("%s: frame %d = %v, want %v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+		if s.Len() != len(tt.want) || s.Position() != len(tt.want) {
+			t.Errorf("%s: length %d, position %d after decoding, want %d", tt.name, s.Len(), s.Position(), len(tt.want))
+		}
+	}
+}
+
+func TestDecodeAIFFInvalid(t *testing.T) {
+	valid := aiffFile{channels: 2, bits: 16, rate: 44100, data: pcm(16, true, 0, 0)}.bytes()
+	noSSND := append([]byte(nil), valid[:12]...)
+	noSSND = append(noSSND, valid[12:12+8+18]...)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not FORM", append([]byte("RIFF"), valid[4:]...)},
+		{"not AIFF", append(append([]byte(nil), valid[:8]...), append([]byte("WAVE"), valid[12:]...)...)},
+		{"missing SSND", noSSND},
+		{"zero channels", aiffFile{channels: 0, bits: 16, rate: 44100, frames: 1}.bytes()},
+		{"zero rate", aiffFile{channels: 2, bits: 16, rate: 0, data: pcm(16, true, 0, 0)}.bytes()},
+		{"unsupported sample size", aiffFile{channels: 1, bits: 48, rate: 44100, frames: 1}.bytes()},
+		{"unsupported compression", aiffFile{aifc: true, compression: "ulaw", channels: 1, bits: 8, rate: 8000, data: []byte{0}}.bytes()},
+	}
+	for _, tt := range tests {
+		if _, _, err := DecodeAIFF(openMem(tt.data)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestAIFFSeek(t *testing.T) {
+	const n = 1000
+	samples := make([]int32, 2*n)
+	for i := range n {
+		samples[2*i], samples[2*i+1] = int32(i), int32(-i)
+	}
+	for _, file := range []aiffFile{
+		{channels: 2, bits: 16, rate: 44100, offset: 4, data: pcm(16, true, samples...)},
+		{aifc: true, compression: "sowt", channels: 2, bits: 24, rate: 44100, extra: true, data: pcm(24, false, samples...)},
+	} {
+		s, _, err := DecodeAIFF(openMem(file.bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scale := float64(int64(1) << (file.bits - 1))
+		buf := make([][2]float64, 10)
+		for _, p := range []int{500, 0, 999, 123, n} {
+			if err := s.Seek(p); err != nil {
+				t.Fatalf("%d bit: seek %d: %v", file.bits, p, err)
+			}
+			if s.Position() != p {
+				t.Errorf("%d bit: position %d after seeking to %d", file.bits, s.Position(), p)
+			}
+			got, ok := s.Stream(buf)
+			if want := min(len(buf), n-p); got != want || ok != (want > 0) {
+				t.Errorf("%d bit: read %d, %v at %d, want %d", file.bits, got, ok, p, want)
+			}
+			for i := range got {
+				if l, r := int(math.Round(buf[i][0]*scale)), int(math.Round(buf[i][1]*scale)); l != p+i || r != -(p+i) {
+					t.Errorf("%d bit: frame %d after seeking to %d is %d/%d", file.bits, i, p, l, r)
+				}
+			}
+		}
+		for _, p := range []int{-1, n + 1} {
+			if err := s.Seek(p); err == nil {
+				t.Errorf("%d bit: seek %d: expected an error", file.bits, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,522 @@
+package codec
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+
+	"github.com/faiface/beep"
+)
+
+// 一个简单的 FLAC 编码器：固定块长，每个子帧在常量、原样和 0-4 阶固定预测中选最短的，
+// 残差用分区 Rice 编码，立体声在左右、左侧、右侧、中侧四种声道组合中选最短的
+// 压缩率比 libFLAC 的 LPC 差一些，但文件可以被任何 FLAC 解码器播放
+const (
+	flacBlockSize         = 4096
+	flacMaxFixedOrder     = 4
+	flacMaxPartitionOrder = 6
+	flacMaxRiceParam      = 14 // 4 位 Rice 参数，15 是转义码
+)
+
+// 声道组合，对应帧头中的声道分配字段
+const (
+	flacIndependent = 1 // 对立体声是 2 - 1
+	flacLeftSide    = 8
+	flacRightSide   = 9
+	flacMidSide     = 10
+)
+
+// EncodeFLAC 把 s 中的全部采样编码成 FLAC 写入 w，用法和 wav.Encode 相同
+// Precision 支持 1、2、3 字节，NumChannels 支持 1 和 2
+// 写完后会回到文件开头补上 STREAMINFO 中的总采样数、帧长范围和 MD5
+func EncodeFLAC(w io.WriteSeeker, s beep.Streamer, format beep.Format) error {
+	if format.NumChannels != 1 && format.NumChannels != 2 {
+		return errors.New("flac: only mono and stereo are supported")
+	}
+	if format.Precision < 1 || format.Precision > 3 {
+		return errors.New("flac: unsupported precision, 1, 2 or 3 is supported")
+	}
+	if format.SampleRate <= 0 || format.SampleRate >= 1<<20 {
+		return fmt.Errorf("flac: invalid sample rate %d", format.SampleRate)
+	}
+
+	enc := &flacEncoder{
+		w:          w,
+		channels:   format.NumChannels,
+		bps:        format.Precision * 8,
+		sampleRate: int(format.SampleRate),
+		md5:        md5.New(),
+		minFrame:   math.MaxInt32,
+	}
+	if err := enc.writeStreamInfo(); err != nil {
+		return err
+	}
+
+	block := make([][2]float64, flacBlockSize)
+	for {
+		// 凑满一整块再编码，只有最后一块可以比较短
+		n := 0
+		ok := true
+		for n < len(block) && ok {
+			var sn int
+			sn, ok = s.Stream(block[n:])
+			n += sn
+		}
+		if n > 0 {
+			if err := enc.writeFrame(block[:n]); err != nil {
+				return err
+			}
+		}
+		if !ok {
+			break
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if enc.minFrame > enc.maxFrame {
+		enc.minFrame = 0
+	}
+	if _, err := w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return enc.writeStreamInfo()
+}
+
+type flacEncoder struct {
+	w          io.Writer
+	channels   int
+	bps        int
+	sampleRate int
+
+	frameNumber  uint64
+	totalSamples uint64
+	minFrame     int
+	maxFrame     int
+	md5          interface {
+		io.Writer
+		Sum([]byte) []byte
+	}
+
+	channelBuf [4][]int32 // 左、右、中、侧
+	frameBuf   bitWriter
+}
+
+// writeStreamInfo 写出 "fLaC" 标记和唯一的元数据块 STREAMINFO
+func (e *flacEncoder) writeStreamInfo() error {
+	var bw bitWriter
+	bw.writeBytes([]byte("fLaC"))
+	bw.write(1, 1)   // 最后一个元数据块
+	bw.write(0, 7)   // STREAMINFO
+	bw.write(34, 24) // 块长度
+	bw.write(flacBlockSize, 16)
+	bw.write(flacBlockSize, 16)
+	bw.write(uint64(e.minFrame), 24)
+	bw.write(uint64(e.maxFrame), 24)
+	bw.write(uint64(e.sampleRate), 20)
+	bw.write(uint64(e.channels-1), 3)
+	bw.write(uint64(e.bps-1), 5)
+	bw.write(e.totalSamples, 36)
+	if e.totalSamples > 0 {
+		bw.writeBytes(e.md5.Sum(nil))
+	} else {
+		bw.writeBytes(make([]byte, md5.Size))
+	}
+	_, err := e.w.Write(bw.bytes())
+	return err
+}
+
+func (e *flacEncoder) writeFrame(block [][2]float64) error {
+	n := len(block)
+	for i := range e.channelBuf {
+		if cap(e.channelBuf[i]) < n {
+			e.channelBuf[i] = make([]int32, n)
+		}
+		e.channelBuf[i] = e.channelBuf[i][:n]
+	}
+	left, right, mid, side := e.channelBuf[0], e.channelBuf[1], e.channelBuf[2], e.channelBuf[3]
+
+	scale := float64(int64(1)<<(e.bps-1) - 1)
+	raw := make([]byte, 0, n*e.channels*(e.bps/8))
+	for i, sample := range block {
+		for c := 0; c < e.channels; c++ {
+			v := int32(math.Round(math.Max(-1, math.Min(1, sample[c])) * scale))
+			if c == 0 {
+				left[i] = v
+			} else {
+				right[i] = v
+			}
+			// MD5 按小端有符号整数计算（8 位时同样是有符号）
+			switch e.bps {
+			case 8:
+				raw = append(raw, byte(int8(v)))
+			case 16:
+				raw = binary.LittleEndian.AppendUint16(raw, uint16(int16(v)))
+			case 24:
+				raw = append(raw, byte(v), byte(v>>8), byte(v>>16))
+			}
+		}
+	}
+	e.md5.Write(raw)
+
+	bw := &e.frameBuf
+	bw.reset()
+
+	assignment := flacIndependent
+	var subframes []subframe
+	if e.channels == 1 {
+		subframes = []subframe{encodeSubframe(left, e.bps)}
+	} else {
+		for i := range block {
+			mid[i] = (left[i] + right[i]) >> 1
+			side[i] = left[i] - right[i]
+		}
+		l, r := encodeSubframe(left, e.bps), encodeSubframe(right, e.bps)
+		m, s := encodeSubframe(mid, e.bps), encodeSubframe(side, e.bps+1)
+		subframes = []subframe{l, r}
+		best := l.bits + r.bits
+		if cost := l.bits + s.bits; cost < best {
+			assignment, subframes, best = flacLeftSide, []subframe{l, s}, cost
+		}
+		if cost := s.bits + r.bits; cost < best {
+			assignment, subframes, best = flacRightSide, []subframe{s, r}, cost
+		}
+		if cost := m.bits + s.bits; cost < best {
+			assignment, subframes = flacMidSide, []subframe{m, s}
+		}
+	}
+
+	// 帧头
+	bw.write(0xfff8, 16) // 同步码，固定块长
+	if n == flacBlockSize {
+		bw.write(12, 4) // 256 * 2^(12-8) = 4096
+	} else {
+		bw.write(7, 4) // 块长在帧头末尾用 16 位给出
+	}
+	bw.write(0, 4) // 采样率见 STREAMINFO
+	if assignment == flacIndependent {
+		bw.write(uint64(e.channels-1), 4)
+	} else {
+		bw.write(uint64(assignment), 4)
+	}
+	switch e.bps {
+	case 8:
+		bw.write(1, 3)
+	case 16:
+		bw.write(4, 3)
+	case 24:
+		bw.write(6, 3)
+	}
+	bw.write(0, 1)
+	bw.writeBytes(utf8Number(e.frameNumber))
+	if n != flacBlockSize {
+		bw.write(uint64(n-1), 16)
+	}
+	bw.writeBytes([]byte{crc8(bw.bytes())})
+
+	for _, sf := range subframes {
+		sf.encode(bw)
+	}
+	bw.align()
+	crc := crc16(bw.bytes())
+	bw.write(uint64(crc), 16)
+
+	frame := bw.bytes()
+	if _, err := e.w.Write(frame); err != nil {
+		return err
+	}
+	e.frameNumber++
+	e.totalSamples += uint64(n)
+	e.minFrame = min(e.minFrame, len(frame))
+	e.maxFrame = max(e.maxFrame, len(frame))
+	return nil
+}
+
+// subframe 是一个声道选好编码方式后的结果
+type subframe struct {
+	samples  []int32
+	bps      int
+	kind     int // 0 常量，1 原样，2 固定预测
+	order    int
+	residual []int32
+	params   []int // 每个分区的 Rice 参数
+	bits     int   // 编码后的位数，用来比较不同方案
+}
+
+func encodeSubframe(samples []int32, bps int) subframe {
+	n := len(samples)
+	constant := true
+	for _, v := range samples[1:] {
+		if v != samples[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		return subframe{samples: samples, bps: bps, kind: 0, bits: 8 + bps}
+	}
+
+	best := subframe{samples: samples, bps: bps, kind: 1, bits: 8 + n*bps}
+	residual := make([]int32, n)
+	for order := 0; order <= flacMaxFixedOrder && order < n; order++ {
+		fixedResidual(samples, order, residual)
+		params, cost := riceParams(residual[order:], n, order)
+		cost += 8 + order*bps + 6 // 子帧头、预热采样、残差编码头
+		if cost < best.bits {
+			best = subframe{
+				samples:  samples,
+				bps:      bps,
+				kind:     2,
+				order:    order,
+				residual: append([]int32(nil), residual[order:]...),
+				params:   params,
+				bits:     cost,
+			}
+		}
+	}
+	return best
+}
+
+// fixedResidual 计算固定多项式预测的残差，前 order 个位置不使用
+func fixedResidual(s []int32, order int, out []int32) {
+	for i := order; i < len(s); i++ {
+		var r int64
+		switch order {
+		case 0:
+			r = int64(s[i])
+		case 1:
+			r = int64(s[i]) - int64(s[i-1])
+		case 2:
+			r = int64(s[i]) - 2*int64(s[i-1]) + int64(s[i-2])
+		case 3:
+			r = int64(s[i]) - 3*int64(s[i-1]) + 3*int64(s[i-2]) - int64(s[i-3])
+		case 4:
+			r = int64(s[i]) - 4*int64(s[i-1]) + 6*int64(s[i-2]) - 4*int64(s[i-3]) + int64(s[i-4])
+		}
+		out[i] = int32(r)
+	}
+}
+
+// riceParams 选择分区阶数和每个分区的 Rice 参数，返回参数和残差部分的位数
+func riceParams(residual []int32, blockSize, order int) ([]int, int) {
+	var bestParams []int
+	bestCost := math.MaxInt
+	for po := 0; po <= flacMaxPartitionOrder; po++ {
+		partitions := 1 << po
+		if blockSize%partitions != 0 || blockSize>>po <= order {
+			break
+		}
+		params := make([]int, partitions)
+		cost := 0
+		start := 0
+		for p := 0; p < partitions; p++ {
+			size := blockSize >> po
+			if p == 0 {
+				size -= order
+			}
+			k, c := bestRiceParam(residual[start : start+size])
+			params[p] = k
+			cost += 4 + c
+			start += size
+		}
+		if cost < bestCost {
+			bestParams, bestCost = params, cost
+		}
+	}
+	return bestParams, bestCost
+}
+
+// bestRiceParam 根据平均值估计 Rice 参数，再在附近精确比较
+func bestRiceParam(residual []int32) (int, int) {
+	if len(residual) == 0 {
+		return 0, 0
+	}
+	var sum uint64
+	for _, r := range residual {
+		sum += uint64(zigzag(r))
+	}
+	guess := 0
+	if mean := sum / uint64(len(residual)); mean > 0 {
+		guess = min(bits.Len64(mean)-1, flacMaxRiceParam)
+	}
+	bestK, bestCost := 0, math.MaxInt
+	for k := max(0, guess-1); k <= min(flacMaxRiceParam, guess+1); k++ {
+		cost := len(residual) * (k + 1)
+		for _, r := range residual {
+			cost += int(zigzag(r) >> k)
+		}
+		if cost < bestCost {
+			bestK, bestCost = k, cost
+		}
+	}
+	return bestK, bestCost
+}
+
+func zigzag(r int32) uint32 {
+	return uint32(r<<1) ^ uint32(r>>31)
+}
+
+func (sf subframe) encode(bw *bitWriter) {
+	bw.write(0, 1)
+	switch sf.kind {
+	case 0:
+		bw.write(0, 6)
+		bw.write(0, 1)
+		bw.writeSigned(sf.samples[0], sf.bps)
+	case 1:
+		bw.write(1, 6)
+		bw.write(0, 1)
+		for _, v := range sf.samples {
+			bw.writeSigned(v, sf.bps)
+		}
+	case 2:
+		bw.write(uint64(8|sf.order), 6)
+		bw.write(0, 1)
+		for _, v := range sf.samples[:sf.order] {
+			bw.writeSigned(v, sf.bps)
+		}
+		bw.write(0, 2) // 4 位 Rice 参数
+		po := bits.Len(uint(len(sf.params))) - 1
+		bw.write(uint64(po), 4)
+		blockSize := len(sf.samples)
+		start := 0
+		for p, k := range sf.params {
+			size := blockSize >> po
+			if p == 0 {
+				size -= sf.order
+			}
+			bw.write(uint64(k), 4)
+			for _, r := range sf.residual[start : start+size] {
+				u := zigzag(r)
+				bw.writeUnary(int(u >> k))
+				bw.write(uint64(u)&(1<<k-1), k)
+			}
+			start += size
+		}
+	}
+}
+
+// utf8Number 按 FLAC 帧头的扩展 UTF-8 方式编码帧号
+func utf8Number(x uint64) []byte {
+	if x < 0x80 {
+		return []byte{byte(x)}
+	}
+	// 续字节数和首字节可用的位数
+	var n int
+	switch {
+	case x < 1<<11:
+		n = 1
+	case x < 1<<16:
+		n = 2
+	case x < 1<<21:
+		n = 3
+	case x < 1<<26:
+		n = 4
+	case x < 1<<31:
+		n = 5
+	default:
+		n = 6
+	}
+	out := make([]byte, n+1)
+	for i := n; i > 0; i-- {
+		out[i] = 0x80 | byte(x&0x3f)
+		x >>= 6
+	}
+	out[0] = byte(0xff<<(7-n)) | byte(x)
+	return out
+}
+
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// bitWriter 按高位在前的顺序写入比特
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits int
+}
+
+func (bw *bitWriter) reset() {
+	bw.buf = bw.buf[:0]
+	bw.acc, bw.nbits = 0, 0
+}
+
+func (bw *bitWriter) write(v uint64, n int) {
+	for n > 0 {
+		take := min(n, 56-bw.nbits)
+		bw.acc = bw.acc<<take | (v>>(n-take))&(1<<take-1)
+		bw.nbits += take
+		n -= take
+		for bw.nbits >= 8 {
+			bw.nbits -= 8
+			bw.buf = append(bw.buf, byte(bw.acc>>bw.nbits))
+		}
+		bw.acc &= 1<<bw.nbits - 1
+	}
+}
+
+func (bw *bitWriter) writeSigned(v int32, n int) {
+	bw.write(uint64(int64(v))&(1<<n-1), n)
+}
+
+// writeUnary 写入 q 个 0 和一个 1
+func (bw *bitWriter) writeUnary(q int) {
+	for q >= 32 {
+		bw.write(0, 32)
+		q -= 32
+	}
+	bw.write(1, q+1)
+}
+
+func (bw *bitWriter) writeBytes(b []byte) {
+	if bw.nbits == 0 {
+		bw.buf = append(bw.buf, b...)
+		return
+	}
+	for _, c := range b {
+		bw.write(uint64(c), 8)
+	}
+}
+
+// align 用 0 补齐到整字节
+func (bw *bitWriter) align() {
+	if bw.nbits > 0 {
+		bw.write(0, 8-bw.nbits)
+	}
+}
+
+// bytes 返回已写满的字节，调用前应先 align
+func (bw *bitWriter) bytes() []byte {
+	return bw.buf
+}
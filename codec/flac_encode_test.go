@@ -0,0 +1,162 @@
+package codec
+
+import (
+	"crypto/md5"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/faiface/beep"
+	"github.com/mewkiz/flac"
+)
+
+// testSignal 生成 n 个采样，f(i) 返回第 i 个采样
+func testSignal(n int, f func(i int) [2]float64) [][2]float64 {
+	samples := make([][2]float64, n)
+	for i := range samples {
+		samples[i] = f(i)
+	}
+	return samples
+}
+
+func TestEncodeFLACRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	sine := func(i int) float64 { return 0.8 * math.Sin(2*math.Pi*440*float64(i)/44100) }
+	tests := []struct {
+		name      string
+		channels  int
+		precision int
+		samples   [][2]float64
+	}{
+		{"silence", 2, 2, testSignal(10000, func(int) [2]float64 { return [2]float64{} })},
+		{"constant", 2, 2, testSignal(5000, func(int) [2]float64 { return [2]float64{0.25, -0.25} })},
+		{"identical channels", 2, 2, testSignal(3*flacBlockSize, func(i int) [2]float64 { return [2]float64{sine(i), sine(i)} })},
+		{"independent channels", 2, 2, testSignal(3*flacBlockSize+17, func(i int) [2]float64 {
+			return [2]float64{sine(i), 0.3 * math.Sin(2*math.Pi*1234*float64(i)/44100)}
+		})},
+		{"white noise", 2, 2, testSignal(flacBlockSize+1, func(int) [2]float64 {
+			return [2]float64{rng.Float64()*2 - 1, rng.Float64()*2 - 1}
+		})},
+		// 超出 [-1, 1] 的采样被截断
+		{"clipping", 2, 2, testSignal(2000, func(i int) [2]float64 { return [2]float64{2 * sine(i), -1.5} })},
+		{"mono", 1, 2, testSignal(9000, func(i int) [2]float64 { return [2]float64{sine(i), 0} })},
+		{"8 bit", 2, 1, testSignal(6000, func(i int) [2]float64 { return [2]float64{sine(i), rng.Float64() - 0.5} })},
+		{"24 bit", 2, 3, testSignal(6000, func(i int) [2]float64 { return [2]float64{sine(i), rng.Float64() - 0.5} })},
+		{"single sample", 2, 2, [][2]float64{{0.5, -0.5}}},
+		{"empty", 2, 2, nil},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "test.flac")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		format := beep.Format{SampleRate: 44100, NumChannels: tt.channels, Precision: tt.precision}
+		err = EncodeFLAC(f, sliceStreamer(tt.samples), format)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		stream, err := flac.ParseFile(path)
+		if err != nil {
+			t.Errorf("%s: decode: %v", tt.name, err)
+			continue
+		}
+		info := stream.Info
+		if info.SampleRate != 44100 || int(info.NChannels) != tt.channels || int(info.BitsPerSample) != 8*tt.precision ||
+			info.NSamples != uint64(len(tt.samples)) {
+			t.Errorf("%s: stream info %d Hz %d channels %d bits %d samples, want 44100 %d %d %d", tt.name,
+				info.SampleRate, info.NChannels, info.BitsPerSample, info.NSamples, tt.channels, 8*tt.precision, len(tt.samples))
+		}
+
+		scale := float64(int64(1)<<(8*tt.precision-1) - 1)
+		hash := md5.New()
+		pos := 0
+		for {
+			frame, err := stream.ParseNext()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Errorf("%s: frame at sample %d: %v", tt.name, pos, err)
+				break
+			}
+			frame.Hash(hash)
+			for i := 0; i < int(frame.BlockSize); i++ {
+				for c := 0; c < tt.channels; c++ {
+					want := int32(math.Round(math.Max(-1, math.Min(1, tt.samples[pos+i][c])) * scale))
+					if got := frame.Subframes[c].Samples[i]; got != want {
+						t.Fatalf("%s: sample %d channel %d = %d, want %d", tt.name, pos+i, c, got, want)
+					}
+				}
+			}
+			pos += int(frame.BlockSize)
+		}
+		stream.Close()
+		if pos != len(tt.samples) {
+			t.Errorf("%s: decoded %d samples, want %d", tt.name, pos, len(tt.samples))
+		}
+		if len(tt.samples) > 0 && [md5.Size]byte(hash.Sum(nil)) != info.MD5sum {
+			t.Errorf("%s: MD5 in STREAMINFO does not match the decoded audio", tt.name)
+		}
+	}
+}
+
+func TestEncodeFLACCompresses(t *testing.T) {
+	// 平滑的正弦波用固定预测应该明显比 16 位 PCM 小
+	samples := testSignal(10*flacBlockSize, func(i int) [2]float64 {
+		v := 0.5 * math.Sin(2*math.Pi*440*float64(i)/44100)
+		return [2]float64{v, v}
+	})
+	path := filepath.Join(t.TempDir(), "sine.flac")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := EncodeFLAC(f, sliceStreamer(samples), beep.Format{SampleRate: 44100, NumChannels: 2, Precision: 2}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pcm := int64(len(samples) * 4); info.Size() > pcm/3 {
+		t.Errorf("encoded %d bytes of PCM into %d bytes", pcm, info.Size())
+	}
+}
+
+// sliceStreamer 每次最多输出 1000 个采样，编码器要自己凑满整块
+func sliceStreamer(samples [][2]float64) beep.Streamer {
+	return beep.StreamerFunc(func(buf [][2]float64) (int, bool) {
+		if len(samples) == 0 {
+			return 0, false
+		}
+		n := copy(buf[:min(len(buf), 1000)], samples)
+		samples = samples[n:]
+		return n, true
+	})
+}
+
+func TestEncodeFLACInvalidFormat(t *testing.T) {
+	for _, format := range []beep.Format{
+		{SampleRate: 44100, NumChannels: 3, Precision: 2},
+		{SampleRate: 44100, NumChannels: 2, Precision: 4},
+		{SampleRate: 0, NumChannels: 2, Precision: 2},
+	} {
+		f, err := os.Create(filepath.Join(t.TempDir(), "bad.flac"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := EncodeFLAC(f, sliceStreamer(nil), format); err == nil {
+			t.Errorf("format %+v: expected an error", format)
+		}
+		f.Close()
+	}
+}
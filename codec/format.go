@@ -0,0 +1,149 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/flac"
+	"github.com/faiface/beep/vorbis"
+	"github.com/faiface/beep/wav"
+)
+
+// DecodeFunc 把打开的文件解码成可播放的 streamer，返回的 streamer 关闭时会一并关闭文件
+type DecodeFunc func(rc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error)
+
+// Format 描述一种支持的音频格式
+type Format struct {
+	Name       string
+	Extensions []string
+	Decode     DecodeFunc
+}
+
+// formats 是支持的格式注册表，播放器和目录扫描都只查询这里
+var formats = []*Format{
+	{
+		Name:       "mp3",
+		Extensions: []string{".mp3"},
+		Decode:     DecodeMP3,
+	},
+	{
+		Name:       "flac",
+		Extensions: []string{".flac"},
+		Decode: func(rc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+			s, format, err := flac.Decode(rc)
+			if err != nil {
+				return nil, format, err
+			}
+			return flacDecoder{s}, format, nil
+		},
+	},
+	{
+		Name:       "wav",
+		Extensions: []string{".wav"},
+		Decode: func(rc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+			return wav.Decode(rc)
+		},
+	},
+	{
+		Name:       "ogg",
+		Extensions: []string{".ogg", ".oga"},
+		Decode: func(rc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+			return vorbis.Decode(rc)
+		},
+	},
+	{
+		Name:       "aiff",
+		Extensions: []string{".aiff", ".aif", ".aifc"},
+		Decode:     DecodeAIFF,
+	},
+}
+
+// flacDecoder 包装 beep 的 FLAC 解码器：它读到文件结尾时把 io.EOF 当作错误留在 Err 里，
+// 读完整个文件的调用方（响度、速度分析）会误以为解码失败
+type flacDecoder struct {
+	beep.StreamSeekCloser
+}
+
+func (d flacDecoder) Err() error {
+	if err := d.StreamSeekCloser.Err(); !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Lookup 根据文件扩展名查找格式，不区分大小写
+func Lookup(path string) (*Format, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, f := range formats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// otherExtensions 是音乐目录里常见的非音频文件，列目录时不打开它们
+var otherExtensions = map[string]bool{
+	".cue": true, ".lrc": true, ".m3u": true, ".m3u8": true, ".pls": true,
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true, ".webp": true,
+	".txt": true, ".nfo": true, ".log": true, ".pdf": true, ".ini": true, ".db": true,
+	".md5": true, ".sfv": true, ".accurip": true,
+}
+
+// IsSupported 判断文件是否是支持的音频格式，列目录时对每个文件调用
+// 扩展名认识时直接按扩展名判断（解码时仍然以内容为准），只有没有扩展名或扩展名不认识的文件才打开看文件头
+func IsSupported(path string) bool {
+	if _, ok := Lookup(path); ok {
+		return true
+	}
+	if otherExtensions[strings.ToLower(filepath.Ext(path))] {
+		return false
+	}
+	_, ok := Detect(path)
+	return ok
+}
+
+// Decode 识别文件内容的格式并解码，path 只用于在内容无法识别时参考扩展名
+func Decode(path string, rc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	f, ok := Sniff(rc, path)
+	if !ok {
+		return nil, beep.Format{}, fmt.Errorf("unsupported audio format: %s", filepath.Base(path))
+	}
+	return f.Decode(rc)
+}
+
+// Duration 返回音频文件的时长，MP3 只读取帧头和信息帧，不解码音频
+func Duration(path string) (time.Duration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	format, ok := Sniff(f, path)
+	if !ok {
+		f.Close()
+		return 0, fmt.Errorf("unsupported audio format: %s", filepath.Base(path))
+	}
+	if format.Name == "mp3" {
+		defer f.Close()
+		info, err := ProbeMP3(f)
+		if err != nil {
+			return 0, err
+		}
+		return info.Duration(), nil
+	}
+	streamer, audio, err := format.Decode(f)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	defer streamer.Close()
+	return audio.SampleRate.D(streamer.Len()), nil
+}
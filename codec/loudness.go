@@ -0,0 +1,255 @@
+package codec
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// 按 ITU-R BS.1770-4 和 EBU R128 / Tech 3342 测量响度
+const (
+	loudnessGateAbsolute = -70.0 // LUFS
+	loudnessGateRelative = -10.0 // 综合响度的相对门限（LU）
+	rangeGateRelative    = -20.0 // 响度范围的相对门限（LU）
+	rangeLowPercentile   = 0.10
+	rangeHighPercentile  = 0.95
+
+	subBlocksPerMomentary = 4  // 400ms 的瞬时响度块由 4 个 100ms 子块组成，每次前进 100ms
+	subBlocksPerShortTerm = 30 // 3s 的短期响度块，每次前进 1s
+	shortTermHop          = 10
+
+	truePeakOversample = 4
+	truePeakTaps       = 12 // 每个相位的抽头数，总长 48
+)
+
+// Loudness 是一首曲目（或一张专辑）的响度测量结果
+type Loudness struct {
+	Integrated float64 // 综合响度，LUFS；没有高于门限的内容时为负无穷
+	Range      float64 // 响度范围 LRA，LU
+	TruePeak   float64 // 真峰值，线性幅度（1 为满幅）
+
+	momentary []float64 // 每个瞬时响度块的均方能量，合并专辑时重新计算门限
+	shortTerm []float64 // 每个短期响度块的均方能量
+}
+
+// TruePeakDB 返回以 dBTP 为单位的真峰值
+func (l Loudness) TruePeakDB() float64 {
+	return 20 * math.Log10(l.TruePeak)
+}
+
+// AlbumLoudness 把几首曲目当作连续播放的一整段计算专辑的响度
+func AlbumLoudness(tracks []Loudness) Loudness {
+	var album Loudness
+	for _, t := range tracks {
+		album.momentary = append(album.momentary, t.momentary...)
+		album.shortTerm = append(album.shortTerm, t.shortTerm...)
+		album.TruePeak = max(album.TruePeak, t.TruePeak)
+	}
+	album.Integrated = integratedLoudness(album.momentary)
+	album.Range = loudnessRange(album.shortTerm)
+	return album
+}
+
+func energyToLoudness(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy)
+}
+
+// gatedMean 返回响度高于门限的块的平均能量
+func gatedMean(blocks []float64, threshold float64) (float64, int) {
+	sum, count := 0.0, 0
+	for _, e := range blocks {
+		if energyToLoudness(e) > threshold {
+			sum += e
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return sum / float64(count), count
+}
+
+func integratedLoudness(blocks []float64) float64 {
+	mean, n := gatedMean(blocks, loudnessGateAbsolute)
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	mean, n = gatedMean(blocks, energyToLoudness(mean)+loudnessGateRelative)
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	return energyToLoudness(mean)
+}
+
+func loudnessRange(blocks []float64) float64 {
+	mean, n := gatedMean(blocks, loudnessGateAbsolute)
+	if n == 0 {
+		return 0
+	}
+	threshold := max(loudnessGateAbsolute, energyToLoudness(mean)+rangeGateRelative)
+	var levels []float64
+	for _, e := range blocks {
+		if l := energyToLoudness(e); l > threshold {
+			levels = append(levels, l)
+		}
+	}
+	if len(levels) == 0 {
+		return 0
+	}
+	slices.Sort(levels)
+	low := levels[int(float64(len(levels)-1)*rangeLowPercentile+0.5)]
+	high := levels[int(float64(len(levels)-1)*rangeHighPercentile+0.5)]
+	return high - low
+}
+
+// kFilter 是 K 计权滤波器：高架滤波模拟头部的影响，再加一个高通（RLB 计权）
+type kFilter struct {
+	b [2][3]float64
+	a [2][3]float64
+	x [2][2][2]float64 // [级][声道][历史]
+	y [2][2][2]float64
+}
+
+// newKFilter 按采样率计算 BS.1770 中的两级滤波器系数，48kHz 时与标准给出的系数一致
+func newKFilter(sr beep.SampleRate) *kFilter {
+	f := &kFilter{}
+	rate := float64(sr)
+
+	// 第一级：高架
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / rate)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	f.b[0] = [3]float64{(vh + vb*k/q + k*k) / a0, 2 * (k*k - vh) / a0, (vh - vb*k/q + k*k) / a0}
+	f.a[0] = [3]float64{1, 2 * (k*k - 1) / a0, (1 - k/q + k*k) / a0}
+
+	// 第二级：高通
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / rate)
+	a0 = 1 + k/q + k*k
+	f.b[1] = [3]float64{1, -2, 1}
+	f.a[1] = [3]float64{1, 2 * (k*k - 1) / a0, (1 - k/q + k*k) / a0}
+	return f
+}
+
+func (f *kFilter) process(c int, x float64) float64 {
+	for s := 0; s < 2; s++ {
+		y := f.b[s][0]*x + f.b[s][1]*f.x[s][c][0] + f.b[s][2]*f.x[s][c][1] -
+			f.a[s][1]*f.y[s][c][0] - f.a[s][2]*f.y[s][c][1]
+		f.x[s][c][1], f.x[s][c][0] = f.x[s][c][0], x
+		f.y[s][c][1], f.y[s][c][0] = f.y[s][c][0], y
+		x = y
+	}
+	return x
+}
+
+// truePeakFilter 是 4 倍过采样的多相插值滤波器，用来估计采样点之间的峰值
+var truePeakFilter = func() [truePeakOversample][truePeakTaps]float64 {
+	var phases [truePeakOversample][truePeakTaps]float64
+	length := truePeakOversample * truePeakTaps
+	center := float64(length-1) / 2
+	for p := 0; p < truePeakOversample; p++ {
+		sum := 0.0
+		for j := 0; j < truePeakTaps; j++ {
+			n := float64(p+j*truePeakOversample) - center
+			x := n / truePeakOversample
+			h := 1.0
+			if x != 0 {
+				h = math.Sin(math.Pi*x) / (math.Pi * x)
+			}
+			// Hann 窗
+			h *= 0.5 + 0.5*math.Cos(2*math.Pi*n/float64(length))
+			phases[p][j] = h
+			sum += h
+		}
+		for j := range phases[p] {
+			phases[p][j] /= sum
+		}
+	}
+	return phases
+}()
+
+// LoudnessMeter 逐块接收采样并累计响度测量所需的数据
+type LoudnessMeter struct {
+	channels int
+	filter   *kFilter
+
+	subBlockSize int
+	subCount     int       // 当前子块已经累计的采样数
+	subSum       float64   // 当前子块的平方和（各声道相加）
+	subBlocks    []float64 // 已完成的子块的平方和
+	result       Loudness
+	history      [2][truePeakTaps]float64 // 真峰值插值用的历史采样
+	historyPos   int
+}
+
+// NewLoudnessMeter 创建响度计，channels 为 1 时只测量左声道（单声道文件被解码成左右相同的两路）
+func NewLoudnessMeter(sr beep.SampleRate, channels int) *LoudnessMeter {
+	return &LoudnessMeter{
+		channels:     max(1, min(2, channels)),
+		filter:       newKFilter(sr),
+		subBlockSize: sr.N(100 * time.Millisecond),
+	}
+}
+
+// Write 处理一批采样
+func (m *LoudnessMeter) Write(samples [][2]float64) {
+	for _, s := range samples {
+		for c := 0; c < m.channels; c++ {
+			y := m.filter.process(c, s[c])
+			m.subSum += y * y
+			m.truePeak(c, s[c])
+		}
+		m.historyPos = (m.historyPos + 1) % truePeakTaps
+		m.subCount++
+		if m.subCount == m.subBlockSize {
+			m.finishSubBlock()
+		}
+	}
+}
+
+func (m *LoudnessMeter) truePeak(c int, x float64) {
+	m.history[c][m.historyPos] = x
+	peak := math.Abs(x)
+	for p := range truePeakFilter {
+		y := 0.0
+		for j, h := range truePeakFilter[p] {
+			y += h * m.history[c][(m.historyPos-j+truePeakTaps)%truePeakTaps]
+		}
+		peak = max(peak, math.Abs(y))
+	}
+	m.result.TruePeak = max(m.result.TruePeak, peak)
+}
+
+func (m *LoudnessMeter) finishSubBlock() {
+	m.subBlocks = append(m.subBlocks, m.subSum)
+	m.subSum, m.subCount = 0, 0
+
+	n := len(m.subBlocks)
+	if n >= subBlocksPerMomentary {
+		m.result.momentary = append(m.result.momentary, m.blockEnergy(n-subBlocksPerMomentary, n))
+	}
+	if n >= subBlocksPerShortTerm && (n-subBlocksPerShortTerm)%shortTermHop == 0 {
+		m.result.shortTerm = append(m.result.shortTerm, m.blockEnergy(n-subBlocksPerShortTerm, n))
+	}
+}
+
+// blockEnergy 返回子块 [from, to) 组成的块的均方能量
+func (m *LoudnessMeter) blockEnergy(from, to int) float64 {
+	sum := 0.0
+	for _, s := range m.subBlocks[from:to] {
+		sum += s
+	}
+	return sum / float64((to-from)*m.subBlockSize)
+}
+
+// Result 返回到目前为止的测量结果，不满一个块的尾部不计入响度
+func (m *LoudnessMeter) Result() Loudness {
+	r := m.result
+	r.Integrated = integratedLoudness(r.momentary)
+	r.Range = loudnessRange(r.shortTerm)
+	return r
+}
@@ -0,0 +1,89 @@
+package codec
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/beep"
+)
+
+// segment 是一段正弦波，dBFS 按峰值计算
+type segment struct {
+	seconds float64
+	dbfs    float64 // math.Inf(-1) 表示静音
+}
+
+func measureSine(channels int, freq float64, segments ...segment) Loudness {
+	const sr = 48000
+	meter := NewLoudnessMeter(beep.SampleRate(sr), channels)
+	buf := make([][2]float64, 4800)
+	n := 0
+	for _, s := range segments {
+		amplitude := math.Pow(10, s.dbfs/20)
+		for left := int(s.seconds * sr); left > 0; {
+			chunk := buf[:min(len(buf), left)]
+			for i := range chunk {
+				v := amplitude * math.Sin(2*math.Pi*freq*float64(n)/sr)
+				chunk[i] = [2]float64{v, v}
+				n++
+			}
+			meter.Write(chunk)
+			left -= len(chunk)
+		}
+	}
+	return meter.Result()
+}
+
+func TestLoudness(t *testing.T) {
+	tests := []struct {
+		name       string
+		channels   int
+		freq       float64
+		segments   []segment
+		integrated float64
+		lra        float64 // 负数表示不检查
+		peak       float64 // dBTP
+	}{
+		{"1kHz -20dBFS stereo", 2, 1000, []segment{{20, -20}}, -20, 0, -20},
+		{"1kHz -23dBFS stereo", 2, 1000, []segment{{20, -23}}, -23, 0, -23},
+		// 单声道只算一路，比同样电平的立体声低 3dB
+		{"1kHz -20dBFS mono", 1, 1000, []segment{{20, -20}}, -23.01, 0, -20},
+		// 低于相对门限（-10 LU）的安静段落不计入综合响度；过渡处的 3 秒窗口会算进 LRA，不检查
+		{"quiet passage gated", 2, 1000, []segment{{10, -20}, {10, -50}}, -20, -1, -20},
+		// EBU Tech 3342 第一个测试信号：-20 和 -30 LUFS 各 20 秒，LRA 为 10 LU
+		{"tech 3342 case 1", 2, 1000, []segment{{20, -20}, {20, -30}}, -22.59, 10, -20},
+	}
+	for _, tt := range tests {
+		l := measureSine(tt.channels, tt.freq, tt.segments...)
+		if math.Abs(l.Integrated-tt.integrated) > 0.1 {
+			t.Errorf("%s: integrated %.2f LUFS, want %.2f", tt.name, l.Integrated, tt.integrated)
+		}
+		if tt.lra >= 0 && math.Abs(l.Range-tt.lra) > 0.2 {
+			t.Errorf("%s: LRA %.2f LU, want %.2f", tt.name, l.Range, tt.lra)
+		}
+		if math.Abs(l.TruePeakDB()-tt.peak) > 0.2 {
+			t.Errorf("%s: true peak %.2f dBTP, want %.2f", tt.name, l.TruePeakDB(), tt.peak)
+		}
+	}
+}
+
+func TestLoudnessSilence(t *testing.T) {
+	l := measureSine(2, 1000, segment{5, math.Inf(-1)})
+	if !math.IsInf(l.Integrated, -1) || l.Range != 0 {
+		t.Errorf("silence: integrated %v, LRA %v, want -inf and 0", l.Integrated, l.Range)
+	}
+}
+
+func TestAlbumLoudness(t *testing.T) {
+	a := measureSine(2, 1000, segment{10, -20})
+	b := measureSine(2, 1000, segment{10, -26})
+	album := AlbumLoudness([]Loudness{a, b})
+	// 两段时长相同，按能量平均：10·log10((10^-2 + 10^-2.6) / 2)
+	want := 10 * math.Log10((math.Pow(10, -2)+math.Pow(10, -2.6))/2)
+	if math.Abs(album.Integrated-want) > 0.1 {
+		t.Errorf("album integrated %.2f LUFS, want %.2f", album.Integrated, want)
+	}
+	if album.TruePeak != max(a.TruePeak, b.TruePeak) {
+		t.Errorf("album true peak %v, want %v", album.TruePeak, max(a.TruePeak, b.TruePeak))
+	}
+}
@@ -0,0 +1,531 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/faiface/beep"
+	gomp3 "github.com/hajimehoshi/go-mp3"
+)
+
+// go-mp3 总是输出 16 位立体声
+const (
+	mp3NumChannels = 2
+	mp3Precision   = 2
+	mp3SampleBytes = mp3NumChannels * mp3Precision
+)
+
+const (
+	// 解码器自身的延迟，LAME 记录的编码延迟不包括这一部分
+	mp3DecoderDelay = 529
+	// 从中间开始解码时提前几帧开始，让比特池（bit reservoir）恢复
+	mp3PrerollFrames = 4
+	mp3PrerollBytes  = 4096
+	// 找第一帧时最多向后搜索的字节数
+	mp3SyncSearch = 1 << 16
+)
+
+// mpegHeader 是解析后的 MPEG 音频帧头，只支持 Layer III
+type mpegHeader struct {
+	mpeg1           bool
+	sampleRate      int
+	frameSize       int
+	samplesPerFrame int
+	sideInfoSize    int
+}
+
+var (
+	mpeg1Bitrates = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mpeg2Bitrates = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mpegRates     = [3]int{44100, 48000, 32000}
+)
+
+func parseMPEGHeader(b []byte) (mpegHeader, bool) {
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return mpegHeader{}, false
+	}
+	version := (b[1] >> 3) & 0x03 // 0 MPEG2.5，2 MPEG2，3 MPEG1
+	layer := (b[1] >> 1) & 0x03   // 1 Layer III
+	bitrateIndex := b[2] >> 4
+	rateIndex := (b[2] >> 2) & 0x03
+	if version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 0x0f || rateIndex == 0x03 {
+		return mpegHeader{}, false
+	}
+	padding := int(b[2]>>1) & 1
+	mono := b[3]>>6 == 3
+
+	h := mpegHeader{mpeg1: version == 3, sampleRate: mpegRates[rateIndex]}
+	switch version {
+	case 2:
+		h.sampleRate /= 2
+	case 0:
+		h.sampleRate /= 4
+	}
+	if h.mpeg1 {
+		h.samplesPerFrame = 1152
+		h.frameSize = 144000*mpeg1Bitrates[bitrateIndex]/h.sampleRate + padding
+		h.sideInfoSize = 32
+		if mono {
+			h.sideInfoSize = 17
+		}
+	} else {
+		h.samplesPerFrame = 576
+		h.frameSize = 72000*mpeg2Bitrates[bitrateIndex]/h.sampleRate + padding
+		h.sideInfoSize = 17
+		if mono {
+			h.sideInfoSize = 9
+		}
+	}
+	return h, true
+}
+
+// MP3Info 是不解码音频就能得到的 MP3 时长和定位信息
+// 优先使用 Xing/Info 或 VBRI 头，没有时扫描全部帧头
+type MP3Info struct {
+	SampleRate int
+	Frames     int64  // 音频帧数，不含 Xing/VBRI 信息帧
+	Delay      int    // LAME 标签记录的编码延迟（采样数）
+	Padding    int    // LAME 标签记录的末尾填充（采样数）
+	Source     string // 帧数的来源：xing、info、vbri 或 scan
+
+	samplesPerFrame int
+	gapless         bool // 有 LAME 标签，按延迟和填充裁剪
+	first           mpegHeader
+	infoFrame       int64 // 信息帧的位置，没有信息帧时为 -1
+	dataStart       int64 // 第一个音频帧的位置
+	dataEnd         int64 // 音频数据的结尾（不含 ID3v1 标签）
+
+	xingTOC   []byte // Xing 目录：100 个百分比位置对应的字节位置（1/256）
+	xingBytes int64
+
+	vbriTOC            []int64 // VBRI 目录：每一段的起始字节位置（相对第一个音频帧）
+	vbriFramesPerEntry int
+
+	offsets []int64 // 扫描得到的每一帧位置
+}
+
+// Samples 返回可以播放的采样数，已去掉编码延迟和末尾填充
+func (i *MP3Info) Samples() int {
+	total := i.Frames * int64(i.samplesPerFrame)
+	if i.gapless {
+		total -= int64(i.Delay + i.Padding)
+	}
+	return int(max(total, 0))
+}
+
+// Duration 返回播放时长
+func (i *MP3Info) Duration() time.Duration {
+	return beep.SampleRate(i.SampleRate).D(i.Samples())
+}
+
+// skip 返回解码输出中开头要丢掉的采样数
+func (i *MP3Info) skip() int {
+	if i.gapless {
+		return i.Delay + mp3DecoderDelay
+	}
+	return 0
+}
+
+// ProbeMP3 读取 MP3 的帧头和信息帧得到时长，读取后 r 的位置不确定
+func ProbeMP3(r io.ReadSeeker) (*MP3Info, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	info := &MP3Info{dataEnd: size, infoFrame: -1}
+
+	// 文件末尾的 ID3v1 标签
+	if size >= 128 {
+		tail := make([]byte, 3)
+		if _, err := r.Seek(size-128, io.SeekStart); err == nil {
+			if _, err := io.ReadFull(r, tail); err == nil && string(tail) == "TAG" {
+				info.dataEnd -= 128
+			}
+		}
+	}
+
+	// 文件开头可能有一个或多个 ID3v2 标签
+	start := int64(0)
+	head := make([]byte, 10)
+	for {
+		if _, err := r.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, head); err != nil || !bytes.HasPrefix(head, []byte("ID3")) {
+			break
+		}
+		n, ok := id3v2Size(head)
+		if !ok {
+			break
+		}
+		start += n
+	}
+
+	first, h, ok := syncMP3(r, start, info.dataEnd)
+	if !ok {
+		return nil, errors.New("mp3: no audio frame found")
+	}
+	info.first = h
+	info.SampleRate = h.sampleRate
+	info.samplesPerFrame = h.samplesPerFrame
+	info.dataStart = first
+
+	frame := make([]byte, h.frameSize)
+	if _, err := r.Seek(first, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, frame); err == nil && (info.parseXing(frame) || info.parseVBRI(frame)) {
+		info.infoFrame = first
+		info.dataStart = first + int64(h.frameSize)
+		return info, nil
+	}
+
+	if err := info.scan(r); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// syncMP3 从 from 开始找第一个可信的帧：帧头合法，并且下一帧的帧头也合法
+func syncMP3(r io.ReadSeeker, from, end int64) (int64, mpegHeader, bool) {
+	if _, err := r.Seek(from, io.SeekStart); err != nil {
+		return 0, mpegHeader{}, false
+	}
+	buf := make([]byte, min(mp3SyncSearch, max(end-from, 0)))
+	n, _ := io.ReadFull(r, buf)
+	buf = buf[:n]
+	for i := 0; i+4 <= len(buf); i++ {
+		h, ok := parseMPEGHeader(buf[i:])
+		if !ok {
+			continue
+		}
+		next := i + h.frameSize
+		if from+int64(next) >= end {
+			// 文件里只有这一帧
+			return from + int64(i), h, true
+		}
+		if next+4 <= len(buf) {
+			if h2, ok := parseMPEGHeader(buf[next:]); ok && h2.sampleRate == h.sampleRate && h2.mpeg1 == h.mpeg1 {
+				return from + int64(i), h, true
+			}
+		}
+	}
+	return 0, mpegHeader{}, false
+}
+
+// parseXing 解析第一帧中的 Xing/Info 头和其后的 LAME 标签
+func (i *MP3Info) parseXing(frame []byte) bool {
+	pos := 4 + i.first.sideInfoSize
+	if len(frame) < pos+8 {
+		return false
+	}
+	tag := string(frame[pos : pos+4])
+	if tag != "Xing" && tag != "Info" {
+		return false
+	}
+	flags := binary.BigEndian.Uint32(frame[pos+4:])
+	p := pos + 8
+	if flags&0x01 != 0 {
+		if len(frame) < p+4 {
+			return false
+		}
+		i.Frames = int64(binary.BigEndian.Uint32(frame[p:]))
+		p += 4
+	}
+	if flags&0x02 != 0 && len(frame) >= p+4 {
+		i.xingBytes = int64(binary.BigEndian.Uint32(frame[p:]))
+		p += 4
+	}
+	if flags&0x04 != 0 && len(frame) >= p+100 {
+		i.xingTOC = append([]byte(nil), frame[p:p+100]...)
+		p += 100
+	}
+	if flags&0x08 != 0 {
+		p += 4
+	}
+	if flags&0x01 == 0 || i.Frames == 0 {
+		// 没有帧数时信息帧没有用，当作普通文件扫描
+		i.xingTOC = nil
+		return false
+	}
+	if tag == "Xing" {
+		i.Source = "xing"
+	} else {
+		i.Source = "info"
+	}
+
+	// LAME 标签：9 字节编码器版本，之后第 21 字节开始是 12 位延迟和 12 位填充
+	if len(frame) >= p+24 {
+		encoder := string(frame[p : p+4])
+		if encoder == "LAME" || encoder == "Lavc" || encoder == "Lavf" || encoder == "L3.9" {
+			b := frame[p+21 : p+24]
+			i.Delay = int(b[0])<<4 | int(b[1])>>4
+			i.Padding = int(b[1]&0x0f)<<8 | int(b[2])
+			i.gapless = true
+		}
+	}
+	return true
+}
+
+// parseVBRI 解析 Fraunhofer 编码器写入的 VBRI 头，它固定在帧头后 32 字节处
+func (i *MP3Info) parseVBRI(frame []byte) bool {
+	const pos = 4 + 32
+	if len(frame) < pos+26 || string(frame[pos:pos+4]) != "VBRI" {
+		return false
+	}
+	i.Frames = int64(binary.BigEndian.Uint32(frame[pos+14:]))
+	entries := int(binary.BigEndian.Uint16(frame[pos+18:]))
+	scale := int64(binary.BigEndian.Uint16(frame[pos+20:]))
+	entrySize := int(binary.BigEndian.Uint16(frame[pos+22:]))
+	i.vbriFramesPerEntry = int(binary.BigEndian.Uint16(frame[pos+24:]))
+	if i.Frames == 0 {
+		return false
+	}
+	i.Source = "vbri"
+
+	table := frame[pos+26:]
+	if entrySize < 1 || entrySize > 4 || len(table) < entries*entrySize || i.vbriFramesPerEntry == 0 {
+		return true
+	}
+	offset := int64(0)
+	i.vbriTOC = make([]int64, 0, entries+1)
+	for e := 0; e < entries; e++ {
+		i.vbriTOC = append(i.vbriTOC, offset)
+		var v int64
+		for _, c := range table[e*entrySize : (e+1)*entrySize] {
+			v = v<<8 | int64(c)
+		}
+		offset += v * scale
+	}
+	// 最后再记下结尾的位置，最后一段也能插值
+	i.vbriTOC = append(i.vbriTOC, offset)
+	return true
+}
+
+// scan 没有信息帧时逐帧读取帧头，得到准确的帧数和每一帧的位置
+func (i *MP3Info) scan(r io.ReadSeeker) error {
+	if _, err := r.Seek(i.dataStart, io.SeekStart); err != nil {
+		return err
+	}
+	br := bufio.NewReaderSize(r, 1<<16)
+	pos := i.dataStart
+	for pos+4 <= i.dataEnd {
+		head, err := br.Peek(4)
+		if err != nil {
+			break
+		}
+		h, ok := parseMPEGHeader(head)
+		if !ok || h.sampleRate != i.SampleRate {
+			// 帧之间有垃圾数据时在缓冲区里逐字节找下一个帧头
+			if _, err := br.Discard(1); err != nil {
+				break
+			}
+			pos++
+			continue
+		}
+		if pos+int64(h.frameSize) > i.dataEnd {
+			break
+		}
+		i.offsets = append(i.offsets, pos)
+		if _, err := br.Discard(h.frameSize); err != nil {
+			break
+		}
+		pos += int64(h.frameSize)
+	}
+	i.Frames = int64(len(i.offsets))
+	i.Source = "scan"
+	return nil
+}
+
+// frameOffset 估计第 frame 个音频帧的字节位置，位置不一定正好是帧头
+func (i *MP3Info) frameOffset(frame int64) int64 {
+	if frame <= 0 || i.Frames == 0 {
+		return i.dataStart
+	}
+	switch {
+	case i.offsets != nil:
+		return i.offsets[min(frame, int64(len(i.offsets)-1))]
+	case i.xingTOC != nil && i.xingBytes > 0:
+		// 目录按时间的百分比给出位置，两项之间线性插值
+		percent := float64(frame) / float64(i.Frames) * 100
+		index := min(int(percent), 99)
+		a := float64(i.xingTOC[index])
+		b := 256.0
+		if index < 99 {
+			b = float64(i.xingTOC[index+1])
+		}
+		fraction := (a + (b-a)*(percent-float64(index))) / 256
+		return i.infoFrame + int64(fraction*float64(i.xingBytes))
+	case i.vbriTOC != nil:
+		entry := frame / int64(i.vbriFramesPerEntry)
+		if entry < int64(len(i.vbriTOC)-1) {
+			a, b := i.vbriTOC[entry], i.vbriTOC[entry+1]
+			within := float64(frame%int64(i.vbriFramesPerEntry)) / float64(i.vbriFramesPerEntry)
+			return i.dataStart + a + int64(within*float64(b-a))
+		}
+	}
+	// 没有目录时按平均帧长估计
+	return i.dataStart + frame*(i.dataEnd-i.dataStart)/i.Frames
+}
+
+// seekPoint 返回从哪一帧、哪个字节位置开始解码才能正确输出第 frame 帧
+// 会提前几帧开始以恢复比特池，没有逐帧位置时按目录估计后对齐到真实的帧头
+func (i *MP3Info) seekPoint(r io.ReadSeeker, frame int64) (int64, int64) {
+	if frame <= mp3PrerollFrames {
+		return 0, i.dataStart
+	}
+	if i.offsets != nil {
+		start := min(frame-mp3PrerollFrames, int64(len(i.offsets)-1))
+		return start, i.offsets[start]
+	}
+
+	target := i.frameOffset(frame)
+	if target-mp3PrerollBytes <= i.dataStart {
+		return 0, i.dataStart
+	}
+	start, _, ok := syncMP3(r, target-mp3PrerollBytes, i.dataEnd)
+	if !ok {
+		return 0, i.dataStart
+	}
+	// 从对齐的帧开始数到目标位置，经过的帧都算作预读
+	count := int64(0)
+	pos := start
+	head := make([]byte, 4)
+	for pos < target {
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			break
+		}
+		if _, err := io.ReadFull(r, head); err != nil {
+			break
+		}
+		h, ok := parseMPEGHeader(head)
+		if !ok {
+			break
+		}
+		pos += int64(h.frameSize)
+		count++
+	}
+	if frame-count < 0 {
+		return 0, i.dataStart
+	}
+	return frame - count, start
+}
+
+// DecodeMP3 解码 MP3，时长来自 Xing/Info/VBRI 头或帧扫描，VBR 文件按目录定位
+// 有 LAME 标签时去掉编码延迟和末尾填充，专辑曲目之间没有多余的静音
+func DecodeMP3(rc io.ReadSeekCloser) (beep.StreamSeekCloser, beep.Format, error) {
+	info, err := ProbeMP3(rc)
+	if err != nil {
+		return nil, beep.Format{}, err
+	}
+	d := &mp3Decoder{rc: rc, info: info}
+	if err := d.Seek(0); err != nil {
+		return nil, beep.Format{}, err
+	}
+	format := beep.Format{
+		SampleRate:  beep.SampleRate(info.SampleRate),
+		NumChannels: mp3NumChannels,
+		Precision:   mp3Precision,
+	}
+	return d, format, nil
+}
+
+type mp3Decoder struct {
+	rc      io.ReadSeekCloser
+	info    *MP3Info
+	dec     *gomp3.Decoder
+	pos     int // 已输出的采样数
+	discard int // 重新开始解码后要丢掉的采样数
+	buf     []byte
+	err     error
+}
+
+// onlyReader 隐藏 Seek，go-mp3 遇到可以 Seek 的输入会在打开时扫描整个文件
+type onlyReader struct {
+	io.Reader
+}
+
+func (d *mp3Decoder) Stream(samples [][2]float64) (n int, ok bool) {
+	if d.err != nil || d.dec == nil {
+		return 0, false
+	}
+	total := d.info.Samples()
+	for n < len(samples) && d.pos < total {
+		want := min(len(samples)-n, total-d.pos)
+		if d.discard > 0 {
+			want = d.discard
+		}
+		want = min(want, 1024)
+		if cap(d.buf) < want*mp3SampleBytes {
+			d.buf = make([]byte, want*mp3SampleBytes)
+		}
+		buf := d.buf[:want*mp3SampleBytes]
+		read, err := d.dec.Read(buf)
+		got := read / mp3SampleBytes
+		if d.discard > 0 {
+			d.discard -= got
+		} else {
+			for i := 0; i < got; i++ {
+				samples[n+i][0] = float64(int16(binary.LittleEndian.Uint16(buf[i*4:]))) / (1 << 15)
+				samples[n+i][1] = float64(int16(binary.LittleEndian.Uint16(buf[i*4+2:]))) / (1 << 15)
+			}
+			n += got
+			d.pos += got
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			d.err = fmt.Errorf("mp3: %w", err)
+			break
+		}
+	}
+	return n, n > 0
+}
+
+func (d *mp3Decoder) Err() error {
+	return d.err
+}
+
+func (d *mp3Decoder) Len() int {
+	return d.info.Samples()
+}
+
+func (d *mp3Decoder) Position() int {
+	return d.pos
+}
+
+// Seek 重新打开解码器：定位到目标帧之前的帧，解码后丢掉多余的采样
+func (d *mp3Decoder) Seek(p int) error {
+	if p < 0 || p > d.Len() {
+		return fmt.Errorf("mp3: seek position %v out of range [%v, %v]", p, 0, d.Len())
+	}
+	spf := int64(d.info.samplesPerFrame)
+	target := int64(p + d.info.skip())
+	startFrame, offset := d.info.seekPoint(d.rc, target/spf)
+	if _, err := d.rc.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("mp3: %w", err)
+	}
+	reader := bufio.NewReaderSize(io.LimitReader(d.rc, d.info.dataEnd-offset), 1<<15)
+	dec, err := gomp3.NewDecoder(onlyReader{reader})
+	if err != nil {
+		return fmt.Errorf("mp3: %w", err)
+	}
+	d.dec = dec
+	d.pos = p
+	d.discard = int(target - startFrame*spf)
+	d.err = nil
+	return nil
+}
+
+func (d *mp3Decoder) Close() error {
+	if err := d.rc.Close(); err != nil {
+		return fmt.Errorf("mp3: %w", err)
+	}
+	return nil
+}
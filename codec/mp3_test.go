@@ -0,0 +1,237 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// 44.1kHz、128kbps、立体声的 MPEG1 Layer III 帧头，帧长 417 字节
+var testFrameHeader = []byte{0xff, 0xfb, 0x90, 0x00}
+
+const testFrameSize = 417
+
+func testFrame() []byte {
+	frame := make([]byte, testFrameSize)
+	copy(frame, testFrameHeader)
+	return frame
+}
+
+// xingFrame 生成带 Xing/Info 头的信息帧，delay 为负时不写 LAME 标签
+func xingFrame(tag string, frames, size uint32, toc []byte, delay, padding int) []byte {
+	frame := testFrame()
+	p := 4 + 32
+	copy(frame[p:], tag)
+	flags := uint32(0x01 | 0x02)
+	if toc != nil {
+		flags |= 0x04
+	}
+	binary.BigEndian.PutUint32(frame[p+4:], flags)
+	binary.BigEndian.PutUint32(frame[p+8:], frames)
+	binary.BigEndian.PutUint32(frame[p+12:], size)
+	p += 16
+	if toc != nil {
+		copy(frame[p:], toc)
+		p += 100
+	}
+	if delay >= 0 {
+		copy(frame[p:], "LAME3.100")
+		frame[p+21] = byte(delay >> 4)
+		frame[p+22] = byte(delay<<4) | byte(padding>>8)
+		frame[p+23] = byte(padding)
+	}
+	return frame
+}
+
+// vbriFrame 生成 VBRI 信息帧，目录每项 2 字节
+func vbriFrame(frames uint32, entries []uint16, scale, framesPerEntry uint16) []byte {
+	frame := testFrame()
+	p := 4 + 32
+	copy(frame[p:], "VBRI")
+	binary.BigEndian.PutUint32(frame[p+14:], frames)
+	binary.BigEndian.PutUint16(frame[p+18:], uint16(len(entries)))
+	binary.BigEndian.PutUint16(frame[p+20:], scale)
+	binary.BigEndian.PutUint16(frame[p+22:], 2)
+	binary.BigEndian.PutUint16(frame[p+24:], framesPerEntry)
+	for i, e := range entries {
+		binary.BigEndian.PutUint16(frame[p+26+2*i:], e)
+	}
+	return frame
+}
+
+func TestParseMPEGHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    []byte
+		ok        bool
+		rate      int
+		frameSize int
+		samples   int
+	}{
+		{"mpeg1 128k", []byte{0xff, 0xfb, 0x90, 0x00}, true, 44100, 417, 1152},
+		{"mpeg1 128k padded", []byte{0xff, 0xfb, 0x92, 0x00}, true, 44100, 418, 1152},
+		{"mpeg1 320k 48k", []byte{0xff, 0xfb, 0xe4, 0x00}, true, 48000, 960, 1152},
+		{"mpeg2 64k 22.05k", []byte{0xff, 0xf3, 0x80, 0xc0}, true, 22050, 208, 576},
+		{"mpeg2.5 8k", []byte{0xff, 0xe3, 0x18, 0xc0}, true, 8000, 72, 576},
+		{"layer II", []byte{0xff, 0xfd, 0x90, 0x00}, false, 0, 0, 0},
+		{"free bitrate", []byte{0xff, 0xfb, 0x00, 0x00}, false, 0, 0, 0},
+		{"bad bitrate", []byte{0xff, 0xfb, 0xf0, 0x00}, false, 0, 0, 0},
+		{"reserved rate", []byte{0xff, 0xfb, 0x9c, 0x00}, false, 0, 0, 0},
+		{"reserved version", []byte{0xff, 0xeb, 0x90, 0x00}, false, 0, 0, 0},
+		{"no sync", []byte{0xff, 0x1b, 0x90, 0x00}, false, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		h, ok := parseMPEGHeader(tt.header)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && (h.sampleRate != tt.rate || h.frameSize != tt.frameSize || h.samplesPerFrame != tt.samples) {
+			t.Errorf("%s: got rate %d size %d samples %d, want %d %d %d",
+				tt.name, h.sampleRate, h.frameSize, h.samplesPerFrame, tt.rate, tt.frameSize, tt.samples)
+		}
+	}
+}
+
+func TestProbeMP3(t *testing.T) {
+	frames := func(n int) []byte {
+		return bytes.Repeat(testFrame(), n)
+	}
+	id3v1 := append([]byte("TAG"), make([]byte, 125)...)
+
+	tests := []struct {
+		name      string
+		data      [][]byte
+		source    string
+		frames    int64
+		samples   int
+		skip      int
+		dataStart int64
+	}{
+		{
+			name:   "plain frames",
+			data:   [][]byte{frames(10)},
+			source: "scan", frames: 10, samples: 10 * 1152,
+		},
+		{
+			name:   "id3v2 and id3v1",
+			data:   [][]byte{emptyID3(100), frames(10), id3v1},
+			source: "scan", frames: 10, samples: 10 * 1152, dataStart: 110,
+		},
+		{
+			name:   "two id3v2 tags",
+			data:   [][]byte{emptyID3(20), emptyID3(30), frames(5)},
+			source: "scan", frames: 5, samples: 5 * 1152, dataStart: 70,
+		},
+		{
+			name:   "junk between frames",
+			data:   [][]byte{frames(5), make([]byte, 3000), frames(5)},
+			source: "scan", frames: 10, samples: 10 * 1152,
+		},
+		{
+			name:   "junk before first frame",
+			data:   [][]byte{{0xff, 0xfb, 0x00}, make([]byte, 200), frames(4)},
+			source: "scan", frames: 4, samples: 4 * 1152, dataStart: 203,
+		},
+		{
+			name:   "xing with lame tag",
+			data:   [][]byte{xingFrame("Xing", 1000, 417000, nil, 576, 1000), frames(3)},
+			source: "xing", frames: 1000, samples: 1000*1152 - 576 - 1000, skip: 576 + mp3DecoderDelay,
+			dataStart: testFrameSize,
+		},
+		{
+			name:   "info without lame tag",
+			data:   [][]byte{xingFrame("Info", 200, 83400, nil, -1, 0), frames(3)},
+			source: "info", frames: 200, samples: 200 * 1152,
+			dataStart: testFrameSize,
+		},
+		{
+			name:   "xing without frame count is scanned",
+			data:   [][]byte{xingFrame("Xing", 0, 0, nil, -1, 0), frames(3)},
+			source: "scan", frames: 4, samples: 4 * 1152,
+		},
+		{
+			name:   "vbri",
+			data:   [][]byte{vbriFrame(500, []uint16{100, 200}, 2, 10), frames(3)},
+			source: "vbri", frames: 500, samples: 500 * 1152,
+			dataStart: testFrameSize,
+		},
+	}
+	for _, tt := range tests {
+		info, err := ProbeMP3(bytes.NewReader(bytes.Join(tt.data, nil)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if info.Source != tt.source || info.Frames != tt.frames || info.Samples() != tt.samples ||
+			info.skip() != tt.skip || info.dataStart != tt.dataStart || info.SampleRate != 44100 {
+			t.Errorf("%s: got source %s frames %d samples %d skip %d start %d rate %d, want %s %d %d %d %d 44100",
+				tt.name, info.Source, info.Frames, info.Samples(), info.skip(), info.dataStart, info.SampleRate,
+				tt.source, tt.frames, tt.samples, tt.skip, tt.dataStart)
+		}
+	}
+
+	if _, err := ProbeMP3(bytes.NewReader(make([]byte, 5000))); err == nil {
+		t.Error("no frames: expected an error")
+	}
+}
+
+func TestMP3ScanOffsets(t *testing.T) {
+	data := bytes.Join([][]byte{emptyID3(50), bytes.Repeat(testFrame(), 3), make([]byte, 123), bytes.Repeat(testFrame(), 2)}, nil)
+	info, err := ProbeMP3(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{60, 60 + 417, 60 + 2*417, 60 + 3*417 + 123, 60 + 4*417 + 123}
+	if len(info.offsets) != len(want) {
+		t.Fatalf("got offsets %v, want %v", info.offsets, want)
+	}
+	for i := range want {
+		if info.offsets[i] != want[i] || info.frameOffset(int64(i)) != want[i] {
+			t.Errorf("frame %d: offset %d / %d, want %d", i, info.offsets[i], info.frameOffset(int64(i)), want[i])
+		}
+	}
+}
+
+func TestMP3TOCOffsets(t *testing.T) {
+	// Xing 目录：前一半时间占 1/4 的字节，后一半占 3/4；位置从信息帧（文件开头）算起
+	toc := make([]byte, 100)
+	for i := range toc {
+		if i < 50 {
+			toc[i] = byte(i * 64 / 50)
+		} else {
+			toc[i] = byte(64 + (i-50)*192/50)
+		}
+	}
+	const xingBytes = 1_000_000
+	xing := bytes.Join([][]byte{xingFrame("Xing", 1000, xingBytes, toc, 576, 0), bytes.Repeat(testFrame(), 3)}, nil)
+
+	// VBRI 目录：每 10 帧一项，长度按 scale 放大
+	vbri := bytes.Join([][]byte{vbriFrame(30, []uint16{100, 300, 200}, 2, 10), bytes.Repeat(testFrame(), 3)}, nil)
+
+	tests := []struct {
+		name  string
+		data  []byte
+		frame int64
+		want  int64
+	}{
+		{"xing start", xing, 0, testFrameSize},
+		{"xing quarter", xing, 250, xingBytes * 32 / 256},
+		{"xing half", xing, 500, xingBytes * 64 / 256},
+		{"xing three quarters", xing, 750, xingBytes * 160 / 256},
+		{"vbri entry 0", vbri, 0, testFrameSize},
+		{"vbri entry 1", vbri, 10, testFrameSize + 200},
+		{"vbri entry 2", vbri, 20, testFrameSize + 800},
+		{"vbri within entry", vbri, 15, testFrameSize + 200 + 300},
+		{"vbri last entry", vbri, 25, testFrameSize + 800 + 200},
+	}
+	for _, tt := range tests {
+		info, err := ProbeMP3(bytes.NewReader(tt.data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := info.frameOffset(tt.frame); got != tt.want {
+			t.Errorf("%s: frameOffset(%d) = %d, want %d", tt.name, tt.frame, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// sniffSize 是识别格式时读取的文件头长度
+const sniffSize = 64
+
+// Sniff 根据文件头的魔数识别格式，识别不出时才退回到扩展名（不区分大小写）
+// 读取完成后 r 会被移回开头
+func Sniff(r io.ReadSeeker, hint string) (*Format, bool) {
+	head := make([]byte, sniffSize)
+	n, _ := io.ReadFull(r, head)
+	head = head[:n]
+
+	name := sniffHeader(head)
+	// ID3v2 标签后面紧跟真正的音频数据，跳过标签再看一次
+	if name == "id3" {
+		name = "mp3"
+		if offset, ok := id3v2Size(head); ok {
+			if _, err := r.Seek(offset, io.SeekStart); err == nil {
+				inner := make([]byte, sniffSize)
+				n, _ := io.ReadFull(r, inner)
+				if inner := sniffHeader(inner[:n]); inner != "" && inner != "id3" {
+					name = inner
+				}
+			}
+		}
+	}
+	_, _ = r.Seek(0, io.SeekStart)
+
+	if name != "" {
+		return byName(name)
+	}
+	return Lookup(hint)
+}
+
+// Detect 打开文件并识别格式
+func Detect(path string) (*Format, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	return Sniff(f, path)
+}
+
+func byName(name string) (*Format, bool) {
+	for _, f := range formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// sniffHeader 识别文件头，返回格式名，识别不出时返回空字符串
+func sniffHeader(b []byte) string {
+	switch {
+	case len(b) >= 10 && bytes.HasPrefix(b, []byte("ID3")):
+		return "id3"
+	case bytes.HasPrefix(b, []byte("fLaC")):
+		return "flac"
+	case len(b) >= 12 && bytes.HasPrefix(b, []byte("RIFF")) && string(b[8:12]) == "WAVE":
+		return "wav"
+	case len(b) >= 12 && bytes.HasPrefix(b, []byte("FORM")) && (string(b[8:12]) == "AIFF" || string(b[8:12]) == "AIFC"):
+		return "aiff"
+	case bytes.HasPrefix(b, []byte("OggS")):
+		// Ogg 容器里也可能是 Opus 或 FLAC，只认第一页是 Vorbis 标识头的文件
+		if bytes.Contains(b, []byte("\x01vorbis")) {
+			return "ogg"
+		}
+		return "unsupported"
+	case isMPEGFrame(b):
+		return "mp3"
+	}
+	return ""
+}
+
+// id3v2Size 返回 ID3v2 标签（含头部和可选的尾部）的总长度
+func id3v2Size(b []byte) (int64, bool) {
+	if len(b) < 10 {
+		return 0, false
+	}
+	size := int64(0)
+	for _, c := range b[6:10] {
+		if c&0x80 != 0 {
+			return 0, false
+		}
+		size = size<<7 | int64(c)
+	}
+	size += 10
+	if b[5]&0x10 != 0 {
+		size += 10
+	}
+	return size, true
+}
+
+// isMPEGFrame 判断是否以合法的 MPEG 音频帧头开始
+func isMPEGFrame(b []byte) bool {
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return false
+	}
+	version := (b[1] >> 3) & 0x03
+	layer := (b[1] >> 1) & 0x03
+	bitrate := b[2] >> 4
+	sampleRate := (b[2] >> 2) & 0x03
+	return version != 1 && layer != 0 && bitrate != 0x0f && sampleRate != 0x03
+}
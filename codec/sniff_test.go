@@ -0,0 +1,131 @@
+package codec
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mpegFrame 是 MPEG-1 Layer III、128 kbps、44.1 kHz 的帧头
+var mpegFrame = []byte{0xff, 0xfb, 0x90, 0x64}
+
+// emptyID3 是内容为 size 个零字节的 ID3v2.3 标签
+func emptyID3(size int) []byte {
+	tag := make([]byte, 10+size)
+	copy(tag, "ID3\x03\x00\x00")
+	for i := 0; i < 4; i++ {
+		tag[9-i] = byte(size>>(7*i)) & 0x7f
+	}
+	return tag
+}
+
+func pad(b []byte, n int) []byte {
+	return append(append([]byte(nil), b...), make([]byte, n)...)
+}
+
+func cat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestDetect(t *testing.T) {
+	wav := cat([]byte("RIFF\x24\x00\x00\x00WAVEfmt "), make([]byte, 32))
+	aiff := aiffFile{channels: 2, bits: 16, rate: 44100, data: pcm(16, true, 0, 0)}.bytes()
+	aifc := aiffFile{aifc: true, compression: "sowt", channels: 2, bits: 16, rate: 44100, data: pcm(16, false, 0, 0)}.bytes()
+	vorbis := cat([]byte("OggS\x00\x02"), make([]byte, 22), []byte("\x01vorbis"), make([]byte, 30))
+	opus := cat([]byte("OggS\x00\x02"), make([]byte, 22), []byte("OpusHead"), make([]byte, 30))
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		want    string // 空字符串表示不支持
+	}{
+		{"mp3", "a.mp3", pad(mpegFrame, 100), "mp3"},
+		{"ID3 tag before MPEG frames", "a.mp3", cat(emptyID3(200), pad(mpegFrame, 100)), "mp3"},
+		{"bare frame sync without extension", "track", pad(mpegFrame, 100), "mp3"},
+		{"mp3 with a wrong extension", "a.wav", cat(emptyID3(20), pad(mpegFrame, 100)), "mp3"},
+		{"flac", "a.flac", pad([]byte("fLaC"), 60), "flac"},
+		{"flac named mp3", "a.mp3", pad([]byte("fLaC"), 60), "flac"},
+		// 有些工具会在 FLAC 前面加 ID3 标签
+		{"ID3 tag before flac", "a.flac", cat(emptyID3(50), pad([]byte("fLaC"), 60)), "flac"},
+		{"ogg vorbis", "a.ogg", vorbis, "ogg"},
+		{"ogg vorbis without extension", "a", vorbis, "ogg"},
+		{"ogg opus", "a.ogg", opus, ""},
+		{"wav", "a.wav", wav, "wav"},
+		{"wav with uppercase extension", "A.WAV", wav, "wav"},
+		{"wav named flac", "a.flac", wav, "wav"},
+		{"aiff", "a.aiff", aiff, "aiff"},
+		{"aifc named aif", "a.aif", aifc, "aiff"},
+		{"aiff without extension", "a", aiff, "aiff"},
+		{"RIFF that is not WAVE", "a", cat([]byte("RIFF\x24\x00\x00\x00AVI "), make([]byte, 32)), ""},
+		{"invalid frame header", "a", pad([]byte{0xff, 0xfb, 0xf0, 0x64}, 100), ""},
+		{"text file", "notes", []byte("just some text that is long enough to sniff"), ""},
+		// 内容识别不出时退回到扩展名
+		{"unknown content with audio extension", "a.FLAC", []byte("garbage garbage garbage"), "flac"},
+		{"short file", "a.mp3", []byte("ID"), "mp3"},
+		{"short file without extension", "a", []byte("ID"), ""},
+		{"empty file", "a.wav", nil, "wav"},
+		{"empty file without extension", "a", nil, ""},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.file)
+		if err := os.WriteFile(path, tt.content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if format, ok := Detect(path); ok {
+			got = format.Name
+		}
+		if got != tt.want {
+			t.Errorf("%s: Detect = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, ok := Detect(filepath.Join(t.TempDir(), "missing.mp3")); ok {
+		t.Error("missing file: Detect succeeded")
+	}
+}
+
+func TestSniffRewinds(t *testing.T) {
+	r := openMem(cat(emptyID3(200), pad(mpegFrame, 100)))
+	if _, ok := Sniff(r, ""); !ok {
+		t.Fatal("Sniff failed")
+	}
+	if pos, _ := r.Seek(0, io.SeekCurrent); pos != 0 {
+		t.Errorf("reader left at %d, want 0", pos)
+	}
+}
+
+func TestIsSupported(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		// 扩展名认识时不打开文件，文件不存在也算支持
+		{"known extension", filepath.Join(dir, "missing.mp3"), true},
+		{"known extension, any case", filepath.Join(dir, "missing.Flac"), true},
+		{"cover art", write("cover.jpg", pad(mpegFrame, 100)), false},
+		{"lyrics", write("a.lrc", []byte("[00:01.00]hi")), false},
+		{"no extension", write("track", pad(mpegFrame, 100)), true},
+		{"unknown extension", write("track.bin", pad([]byte("fLaC"), 60)), true},
+		{"unknown extension, not audio", write("track.dat", []byte("nothing to see here")), false},
+	}
+	for _, tt := range tests {
+		if got := IsSupported(tt.path); got != tt.want {
+			t.Errorf("%s: IsSupported(%q) = %v, want %v", tt.name, tt.path, got, tt.want)
+		}
+	}
+}
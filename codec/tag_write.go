@@ -0,0 +1,381 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TagField 是一个要写入的文本标签，名字使用 Vorbis 注释的大写形式，例如 REPLAYGAIN_TRACK_GAIN
+type TagField struct {
+	Name  string
+	Value string
+}
+
+// WriteTags 把文本标签写入 FLAC 的 Vorbis 注释或 MP3 的 ID3v2 TXXX 帧
+// 已有的同名标签会被替换；现有的标签空间（含填充）放得下时原地修改，否则重写整个文件
+func WriteTags(path string, fields []TagField) error {
+	format, ok := Detect(path)
+	if !ok {
+		return fmt.Errorf("unsupported audio format: %s", filepath.Base(path))
+	}
+	switch format.Name {
+	case "flac":
+		return writeFLACTags(path, fields)
+	case "mp3":
+		return writeID3Tags(path, fields)
+	}
+	return fmt.Errorf("writing tags to %s files is not supported", format.Name)
+}
+
+// replaceRegion 把文件开头 [0, oldSize) 的内容换成 head
+// 长度相同时直接覆盖，否则写到同目录的临时文件后替换原文件
+func replaceRegion(path string, oldSize int64, head []byte) error {
+	if int64(len(head)) == oldSize {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		if _, err := f.WriteAt(head, 0); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(head); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := src.Seek(oldSize, io.SeekStart); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Rename(tmp.Name(), path)
+}
+
+func isReplaced(name string, fields []TagField) bool {
+	for _, f := range fields {
+		if strings.EqualFold(f.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// FLAC 元数据块类型
+const (
+	flacBlockStreamInfo    = 0
+	flacBlockPadding       = 1
+	flacBlockVorbisComment = 4
+)
+
+// tagPadding 是重写文件时在标签后留出的填充，下次修改不必再重写整个文件
+const tagPadding = 1024
+
+type flacBlock struct {
+	kind byte
+	data []byte
+}
+
+// writeFLACTags 修改 VORBIS_COMMENT 块，没有时新建一个
+func writeFLACTags(path string, fields []TagField) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	blocks, size, err := readFLACBlocks(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	// 去掉原有的填充，最后按剩余空间重新补上
+	var kept []flacBlock
+	found := false
+	for _, b := range blocks {
+		switch b.kind {
+		case flacBlockPadding:
+			continue
+		case flacBlockVorbisComment:
+			data, err := updateVorbisComment(b.data, fields)
+			if err != nil {
+				return err
+			}
+			b.data = data
+			found = true
+		}
+		kept = append(kept, b)
+	}
+	if !found {
+		data, _ := updateVorbisComment(nil, fields)
+		// STREAMINFO 必须是第一个块
+		kept = append(kept[:1], append([]flacBlock{{kind: flacBlockVorbisComment, data: data}}, kept[1:]...)...)
+	}
+
+	used := int64(4)
+	for _, b := range kept {
+		used += 4 + int64(len(b.data))
+	}
+	padding := int64(tagPadding)
+	if free := size - used - 4; free >= 0 {
+		// 原来的空间放得下时用填充补齐，文件长度不变
+		padding = free
+	}
+	kept = append(kept, flacBlock{kind: flacBlockPadding, data: make([]byte, padding)})
+
+	var head bytes.Buffer
+	head.WriteString("fLaC")
+	for i, b := range kept {
+		kind := b.kind
+		if i == len(kept)-1 {
+			kind |= 0x80
+		}
+		n := len(b.data)
+		head.Write([]byte{kind, byte(n >> 16), byte(n >> 8), byte(n)})
+		head.Write(b.data)
+	}
+	return replaceRegion(path, size, head.Bytes())
+}
+
+// readFLACBlocks 读取所有元数据块，返回元数据部分（含 fLaC 标记）的总长度
+func readFLACBlocks(r io.Reader) ([]flacBlock, int64, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != "fLaC" {
+		return nil, 0, errors.New("flac: missing fLaC signature")
+	}
+	var blocks []flacBlock
+	size := int64(4)
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, 0, fmt.Errorf("flac: %w", err)
+		}
+		n := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, 0, fmt.Errorf("flac: %w", err)
+		}
+		blocks = append(blocks, flacBlock{kind: header[0] & 0x7f, data: data})
+		size += 4 + int64(n)
+		if header[0]&0x80 != 0 {
+			break
+		}
+	}
+	if len(blocks) == 0 || blocks[0].kind != flacBlockStreamInfo {
+		return nil, 0, errors.New("flac: first metadata block is not STREAMINFO")
+	}
+	return blocks, size, nil
+}
+
+// updateVorbisComment 替换 Vorbis 注释中的字段，data 为空时新建（长度字段是小端序）
+func updateVorbisComment(data []byte, fields []TagField) ([]byte, error) {
+	vendor := []byte("music-cli")
+	var comments [][]byte
+	if len(data) > 0 {
+		r := bytes.NewReader(data)
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return nil, errors.New("flac: invalid vorbis comment")
+		}
+		vendor = make([]byte, n)
+		if _, err := io.ReadFull(r, vendor); err != nil {
+			return nil, errors.New("flac: invalid vorbis comment")
+		}
+		var count uint32
+		if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+			return nil, errors.New("flac: invalid vorbis comment")
+		}
+		for i := uint32(0); i < count; i++ {
+			if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+				return nil, errors.New("flac: invalid vorbis comment")
+			}
+			comment := make([]byte, n)
+			if _, err := io.ReadFull(r, comment); err != nil {
+				return nil, errors.New("flac: invalid vorbis comment")
+			}
+			name, _, _ := bytes.Cut(comment, []byte("="))
+			if !isReplaced(string(name), fields) {
+				comments = append(comments, comment)
+			}
+		}
+	}
+	for _, f := range fields {
+		comments = append(comments, []byte(strings.ToUpper(f.Name)+"="+f.Value))
+	}
+
+	var out bytes.Buffer
+	_ = binary.Write(&out, binary.LittleEndian, uint32(len(vendor)))
+	out.Write(vendor)
+	_ = binary.Write(&out, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		_ = binary.Write(&out, binary.LittleEndian, uint32(len(c)))
+		out.Write(c)
+	}
+	return out.Bytes(), nil
+}
+
+// writeID3Tags 在 ID3v2.3 / 2.4 标签中替换同名的 TXXX 帧，没有标签时新建 ID3v2.4 标签
+// 不认识的标签格式（v2.2、整体反同步、扩展头、尾部标记）不修改，避免损坏文件
+func writeID3Tags(path string, fields []TagField) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 10)
+	_, err = io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		f.Close()
+		return err
+	}
+
+	version := byte(4)
+	var frames []byte
+	size := int64(0)
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		version = header[3]
+		flags := header[5]
+		if version != 3 && version != 4 {
+			f.Close()
+			return fmt.Errorf("id3: unsupported tag version 2.%d", version)
+		}
+		if flags&0xd0 != 0 {
+			f.Close()
+			return errors.New("id3: tags with unsynchronisation, extended header or footer are not supported")
+		}
+		n, ok := id3v2Size(header)
+		if !ok {
+			f.Close()
+			return errors.New("id3: invalid tag size")
+		}
+		size = n
+		body := make([]byte, n-10)
+		if _, err := io.ReadFull(f, body); err != nil {
+			f.Close()
+			return fmt.Errorf("id3: %w", err)
+		}
+		frames, err = filterID3Frames(body, version, fields)
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	f.Close()
+
+	for _, field := range fields {
+		frames = append(frames, id3TextFrame(version, field)...)
+	}
+
+	// 原来的空间放得下时用 0 填充，文件长度不变；否则留出一些填充
+	bodySize := int64(len(frames)) + tagPadding
+	if free := size - 10 - int64(len(frames)); size > 0 && free >= 0 {
+		bodySize = size - 10
+	}
+	head := make([]byte, 10+bodySize)
+	copy(head, []byte{'I', 'D', '3', version, 0, 0})
+	putSynchsafe(head[6:10], uint32(bodySize))
+	copy(head[10:], frames)
+	return replaceRegion(path, size, head)
+}
+
+// filterID3Frames 返回去掉将被替换的 TXXX 帧之后的帧数据，不含填充
+func filterID3Frames(body []byte, version byte, fields []TagField) ([]byte, error) {
+	var out []byte
+	for len(body) >= 10 && body[0] != 0 {
+		var n int
+		if version == 4 {
+			n = int(body[4]&0x7f)<<21 | int(body[5]&0x7f)<<14 | int(body[6]&0x7f)<<7 | int(body[7]&0x7f)
+		} else {
+			n = int(binary.BigEndian.Uint32(body[4:8]))
+		}
+		if n < 0 || 10+n > len(body) {
+			return nil, errors.New("id3: invalid frame size")
+		}
+		frame := body[:10+n]
+		body = body[10+n:]
+		if string(frame[:4]) == "TXXX" && isReplaced(id3Description(frame[10:]), fields) {
+			continue
+		}
+		out = append(out, frame...)
+	}
+	return out, nil
+}
+
+// id3Description 返回 TXXX 帧的描述，只需要识别 ASCII 名字
+func id3Description(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	encoding, text := data[0], data[1:]
+	if encoding == 1 || encoding == 2 {
+		// UTF-16：去掉 BOM 后取每个字符的低字节
+		if encoding == 1 && len(text) >= 2 {
+			text = text[2:]
+		}
+		var name []byte
+		for i := 0; i+1 < len(text); i += 2 {
+			c := text[i] | text[i+1]
+			if c == 0 {
+				break
+			}
+			name = append(name, c)
+		}
+		return string(name)
+	}
+	name, _, _ := bytes.Cut(text, []byte{0})
+	return string(name)
+}
+
+// id3TextFrame 生成 TXXX 帧，文本使用 ISO-8859-1 编码（ReplayGain 的值都是 ASCII）
+func id3TextFrame(version byte, field TagField) []byte {
+	data := append([]byte{0}, []byte(strings.ToUpper(field.Name))...)
+	data = append(data, 0)
+	data = append(data, []byte(field.Value)...)
+
+	frame := make([]byte, 10, 10+len(data))
+	copy(frame, "TXXX")
+	if version == 4 {
+		putSynchsafe(frame[4:8], uint32(len(data)))
+	} else {
+		binary.BigEndian.PutUint32(frame[4:8], uint32(len(data)))
+	}
+	return append(frame, data...)
+}
+
+func putSynchsafe(b []byte, n uint32) {
+	b[0] = byte(n>>21) & 0x7f
+	b[1] = byte(n>>14) & 0x7f
+	b[2] = byte(n>>7) & 0x7f
+	b[3] = byte(n) & 0x7f
+}
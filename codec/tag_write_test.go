@@ -0,0 +1,299 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/dhowden/tag"
+)
+
+var testFields = []TagField{
+	{Name: "REPLAYGAIN_TRACK_GAIN", Value: "-6.50 dB"},
+	{Name: "replaygain_track_peak", Value: "0.900000"},
+}
+
+// writeTestFile 把 parts 拼起来写到临时文件，返回路径
+func writeTestFile(t *testing.T, name string, parts ...[]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, bytes.Join(parts, nil), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// checkAudio 检查标签之后的音频数据原样保留，返回标签部分的长度
+func checkAudio(t *testing.T, name, path string, audio []byte) int64 {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(data, audio) {
+		t.Errorf("%s: audio data changed", name)
+	}
+	return int64(len(data) - len(audio))
+}
+
+func checkTitle(t *testing.T, name, path, want string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := tag.ReadFrom(f)
+	if err != nil {
+		t.Errorf("%s: rewritten tags are unreadable: %v", name, err)
+		return
+	}
+	if m.Title() != want {
+		t.Errorf("%s: title %q, want %q", name, m.Title(), want)
+	}
+}
+
+func flacHeader(blocks ...flacBlock) []byte {
+	out := []byte("fLaC")
+	for i, b := range blocks {
+		kind := b.kind
+		if i == len(blocks)-1 {
+			kind |= 0x80
+		}
+		n := len(b.data)
+		out = append(out, kind, byte(n>>16), byte(n>>8), byte(n))
+		out = append(out, b.data...)
+	}
+	return out
+}
+
+func vorbisComment(vendor string, comments ...string) []byte {
+	var out bytes.Buffer
+	_ = binary.Write(&out, binary.LittleEndian, uint32(len(vendor)))
+	out.WriteString(vendor)
+	_ = binary.Write(&out, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		_ = binary.Write(&out, binary.LittleEndian, uint32(len(c)))
+		out.WriteString(c)
+	}
+	return out.Bytes()
+}
+
+// readVorbisComment 返回文件中的元数据块和 Vorbis 注释的厂商字符串、注释列表
+func readVorbisComment(t *testing.T, path string) ([]flacBlock, string, []string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	blocks, _, err := readFLACBlocks(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range blocks {
+		if b.kind != flacBlockVorbisComment {
+			continue
+		}
+		data := b.data
+		n := binary.LittleEndian.Uint32(data)
+		vendor := string(data[4 : 4+n])
+		data = data[4+n:]
+		count := binary.LittleEndian.Uint32(data)
+		data = data[4:]
+		var comments []string
+		for range count {
+			n := binary.LittleEndian.Uint32(data)
+			comments = append(comments, string(data[4:4+n]))
+			data = data[4+n:]
+		}
+		return blocks, vendor, comments
+	}
+	return blocks, "", nil
+}
+
+func TestWriteFLACTags(t *testing.T) {
+	streamInfo := flacBlock{kind: flacBlockStreamInfo, data: make([]byte, 34)}
+	comment := flacBlock{kind: flacBlockVorbisComment, data: vorbisComment("reference libFLAC 1.4.3",
+		"TITLE=Song", "replaygain_track_gain=-1.00 dB", "ARTIST=Someone")}
+	padding := func(n int) flacBlock { return flacBlock{kind: flacBlockPadding, data: make([]byte, n)} }
+	seekTable := flacBlock{kind: 3, data: make([]byte, 18)}
+	audio := bytes.Repeat([]byte{0xff, 0xf8, 0x12, 0x34}, 100)
+	replaced := []string{"TITLE=Song", "ARTIST=Someone", "REPLAYGAIN_TRACK_GAIN=-6.50 dB", "REPLAYGAIN_TRACK_PEAK=0.900000"}
+
+	tests := []struct {
+		name     string
+		blocks   []flacBlock
+		vendor   string
+		comments []string
+		inPlace  bool
+	}{
+		{"padding reused", []flacBlock{streamInfo, comment, seekTable, padding(4096)}, "reference libFLAC 1.4.3", replaced, true},
+		{"exact fit", []flacBlock{streamInfo, comment, padding(34)}, "reference libFLAC 1.4.3", replaced, true},
+		{"no padding", []flacBlock{streamInfo, comment, seekTable}, "reference libFLAC 1.4.3", replaced, false},
+		{"padding too small", []flacBlock{streamInfo, comment, padding(20)}, "reference libFLAC 1.4.3", replaced, false},
+		{"no vorbis comment", []flacBlock{streamInfo, seekTable, padding(8)}, "music-cli",
+			[]string{"REPLAYGAIN_TRACK_GAIN=-6.50 dB", "REPLAYGAIN_TRACK_PEAK=0.900000"}, false},
+	}
+	for _, tt := range tests {
+		head := flacHeader(tt.blocks...)
+		path := writeTestFile(t, "test.flac", head, audio)
+		if err := WriteTags(path, testFields); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		size := checkAudio(t, tt.name, path, audio)
+		if inPlace := size == int64(len(head)); inPlace != tt.inPlace {
+			t.Errorf("%s: tag size %d -> %d, want in place %v", tt.name, len(head), size, tt.inPlace)
+		}
+		blocks, vendor, comments := readVorbisComment(t, path)
+		if vendor != tt.vendor || !slices.Equal(comments, tt.comments) {
+			t.Errorf("%s: got vendor %q comments %q, want %q %q", tt.name, vendor, comments, tt.vendor, tt.comments)
+		}
+		// STREAMINFO 仍是第一个块，填充在最后；重写时留出 tagPadding
+		if blocks[0].kind != flacBlockStreamInfo || blocks[1].kind != flacBlockVorbisComment {
+			t.Errorf("%s: metadata blocks out of order", tt.name)
+		}
+		last := blocks[len(blocks)-1]
+		if last.kind != flacBlockPadding || (!tt.inPlace && len(last.data) != tagPadding) {
+			t.Errorf("%s: last block is type %d with %d bytes, want padding", tt.name, last.kind, len(last.data))
+		}
+		if !slices.ContainsFunc(blocks, func(b flacBlock) bool { return b.kind == seekTable.kind }) &&
+			slices.ContainsFunc(tt.blocks, func(b flacBlock) bool { return b.kind == seekTable.kind }) {
+			t.Errorf("%s: seek table was dropped", tt.name)
+		}
+		if tt.vendor != "music-cli" {
+			checkTitle(t, tt.name, path, "Song")
+		}
+	}
+
+	path := writeTestFile(t, "bad.flac", []byte("fLaX"), audio)
+	if err := WriteTags(path, testFields); err == nil {
+		t.Error("invalid flac: expected an error")
+	}
+}
+
+func testID3Frame(version byte, id string, data []byte) []byte {
+	frame := make([]byte, 10, 10+len(data))
+	copy(frame, id)
+	if version == 4 {
+		putSynchsafe(frame[4:8], uint32(len(data)))
+	} else {
+		binary.BigEndian.PutUint32(frame[4:8], uint32(len(data)))
+	}
+	return append(frame, data...)
+}
+
+func testID3Tag(version, flags byte, padding int, frames ...[]byte) []byte {
+	body := append(bytes.Join(frames, nil), make([]byte, padding)...)
+	head := []byte{'I', 'D', '3', version, 0, flags, 0, 0, 0, 0}
+	putSynchsafe(head[6:10], uint32(len(body)))
+	return append(head, body...)
+}
+
+// readID3Frames 返回标签版本、每个帧的 "ID=文本"（TXXX 帧是 "描述=值"）和填充长度
+func readID3Frames(t *testing.T, path string) (byte, []string, int) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, ok := id3v2Size(data)
+	if !bytes.HasPrefix(data, []byte("ID3")) || !ok {
+		t.Fatal("missing id3v2 tag")
+	}
+	version := data[3]
+	body, err := filterID3Frames(data[10:size], version, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := int(size) - 10 - len(body)
+	var frames []string
+	for len(body) > 0 {
+		var n int
+		if version == 4 {
+			n = int(body[4])<<21 | int(body[5])<<14 | int(body[6])<<7 | int(body[7])
+		} else {
+			n = int(binary.BigEndian.Uint32(body[4:8]))
+		}
+		id, text := string(body[:4]), body[11:10+n]
+		if id == "TXXX" {
+			desc, value, _ := bytes.Cut(text, []byte{0})
+			frames = append(frames, string(desc)+"="+string(value))
+		} else {
+			frames = append(frames, id+"="+string(text))
+		}
+		body = body[10+n:]
+	}
+	return version, frames, padding
+}
+
+func TestWriteID3Tags(t *testing.T) {
+	audio := bytes.Repeat(testFrame(), 3)
+	title := func(v byte) []byte { return testID3Frame(v, "TIT2", []byte("\x00Song")) }
+	gain := func(v byte) []byte { return testID3Frame(v, "TXXX", []byte("\x00REPLAYGAIN_TRACK_GAIN\x00-1.00 dB")) }
+	other := func(v byte) []byte { return testID3Frame(v, "TXXX", []byte("\x00MOOD\x00calm")) }
+	// UTF-16 编码（带 BOM）的描述也能识别出来
+	utf16Gain := testID3Frame(3, "TXXX", []byte("\x01\xff\xfer\x00e\x00p\x00l\x00a\x00y\x00g\x00a\x00i\x00n\x00_\x00t\x00r\x00a\x00c\x00k\x00_\x00p\x00e\x00a\x00k\x00\x00\x00"))
+	replaced := []string{"TIT2=Song", "MOOD=calm", "REPLAYGAIN_TRACK_GAIN=-6.50 dB", "REPLAYGAIN_TRACK_PEAK=0.900000"}
+
+	tests := []struct {
+		name    string
+		tag     []byte
+		version byte
+		frames  []string
+		inPlace bool
+	}{
+		{"v2.3 padding reused", testID3Tag(3, 0, 2048, title(3), gain(3), other(3)), 3, replaced, true},
+		{"v2.4 padding reused", testID3Tag(4, 0, 2048, title(4), gain(4), other(4)), 4, replaced, true},
+		{"v2.3 utf-16 description", testID3Tag(3, 0, 2048, title(3), utf16Gain, other(3)), 3, replaced, true},
+		{"v2.3 no padding", testID3Tag(3, 0, 0, title(3), gain(3), other(3)), 3, replaced, false},
+		{"v2.4 padding too small", testID3Tag(4, 0, 16, title(4), gain(4), other(4)), 4, replaced, false},
+		{"no tag", nil, 4, []string{"REPLAYGAIN_TRACK_GAIN=-6.50 dB", "REPLAYGAIN_TRACK_PEAK=0.900000"}, false},
+	}
+	for _, tt := range tests {
+		path := writeTestFile(t, "test.mp3", tt.tag, audio)
+		if err := WriteTags(path, testFields); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		size := checkAudio(t, tt.name, path, audio)
+		if inPlace := size == int64(len(tt.tag)); inPlace != tt.inPlace {
+			t.Errorf("%s: tag size %d -> %d, want in place %v", tt.name, len(tt.tag), size, tt.inPlace)
+		}
+		version, frames, padding := readID3Frames(t, path)
+		if version != tt.version || !slices.Equal(frames, tt.frames) {
+			t.Errorf("%s: got v2.%d frames %q, want v2.%d %q", tt.name, version, frames, tt.version, tt.frames)
+		}
+		if !tt.inPlace && padding != tagPadding {
+			t.Errorf("%s: rewritten tag has %d bytes of padding, want %d", tt.name, padding, tagPadding)
+		}
+		if tt.tag != nil {
+			checkTitle(t, tt.name, path, "Song")
+		}
+	}
+
+	// 不支持的标签原样保留
+	for _, tt := range []struct {
+		name string
+		tag  []byte
+	}{
+		{"unsynchronisation", testID3Tag(3, 0x80, 100, title(3))},
+		{"extended header", testID3Tag(4, 0x40, 100, title(4))},
+		{"v2.2", testID3Tag(2, 0, 100)},
+	} {
+		data := append(slices.Clip(tt.tag), audio...)
+		path := writeTestFile(t, "test.mp3", data)
+		if err := WriteTags(path, testFields); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+		if got, _ := os.ReadFile(path); !bytes.Equal(got, data) {
+			t.Errorf("%s: file was modified", tt.name)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package codec
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+
+	"github.com/faiface/beep"
+)
+
+// 离线估计速度（BPM）和调性：先降采样成单声道，
+// 速度用频谱通量的起音包络做自相关，调性用色度向量和 Krumhansl-Kessler 调性轮廓做相关
+const (
+	analysisRate = 11025 // 降采样后的大致采样率，只分析 5kHz 以下的内容
+
+	onsetWindow      = 512
+	onsetHop         = 128  // 约 11ms 一帧
+	onsetCompression = 1000 // 对数压缩幅度，轻的起音也能算进来
+	onsetMeanWindow  = 0.5  // 减去前后 0.5 秒内的平均值，只保留突出的起音
+
+	tempoMinBPM      = 60.0
+	tempoMaxBPM      = 200.0
+	tempoPriorBPM    = 120.0 // 倍速、半速不好区分时偏向接近这个速度的结果
+	tempoRefineBeats = 4     // 在 4 拍之外的自相关峰上细化，精度比一拍高 4 倍
+
+	chromaWindow  = 4096
+	chromaHop     = 2048
+	chromaMinFreq = 100.0
+	chromaMaxFreq = 2000.0
+)
+
+// Key 是调性，0 - 11 为 C 到 B 大调，12 - 23 为 C 到 B 小调
+type Key int
+
+// NoKey 表示没有检测出调性（例如整首都是静音或噪声）
+const NoKey Key = -1
+
+var keyNames = [12]string{"C", "C#", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B"}
+
+// Krumhansl-Kessler 调性轮廓，从主音开始
+var (
+	majorProfile = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	minorProfile = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+func (k Key) valid() bool {
+	return k >= 0 && k < 24
+}
+
+// Minor 判断是否是小调
+func (k Key) Minor() bool {
+	return k >= 12
+}
+
+// String 返回调名，小调加 m，例如 Am、F#
+func (k Key) String() string {
+	if !k.valid() {
+		return ""
+	}
+	if k.Minor() {
+		return keyNames[k-12] + "m"
+	}
+	return keyNames[k]
+}
+
+// camelotNumber 返回 Camelot 调性轮上的数字，关系大小调的数字相同
+func (k Key) camelotNumber() int {
+	tonic := int(k) % 12
+	if k.Minor() {
+		tonic = (tonic + 3) % 12
+	}
+	// C 大调是 8B，顺时针每一格升五度
+	return (tonic*7+7)%12 + 1
+}
+
+// Camelot 返回 Camelot 记号，例如 8A（A 小调）、8B（C 大调）
+func (k Key) Camelot() string {
+	if !k.valid() {
+		return ""
+	}
+	if k.Minor() {
+		return fmt.Sprintf("%dA", k.camelotNumber())
+	}
+	return fmt.Sprintf("%dB", k.camelotNumber())
+}
+
+// CamelotOrder 返回按调性轮排列的序号（1A、1B、2A ...），相邻的曲目容易和谐地衔接；没有调性时为 -1
+func (k Key) CamelotOrder() int {
+	if !k.valid() {
+		return -1
+	}
+	order := (k.camelotNumber() - 1) * 2
+	if !k.Minor() {
+		order++
+	}
+	return order
+}
+
+// Compatible 判断两个调性能否和谐混音：相同、调性轮上相邻或者是关系大小调
+func (k Key) Compatible(other Key) bool {
+	if !k.valid() || !other.valid() {
+		return false
+	}
+	a, b := k.camelotNumber(), other.camelotNumber()
+	if k.Minor() != other.Minor() {
+		return a == b
+	}
+	d := (a - b + 12) % 12
+	return d == 0 || d == 1 || d == 11
+}
+
+// ParseCamelot 解析 8A、12b 之类的 Camelot 记号
+func ParseCamelot(s string) (Key, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return NoKey, false
+	}
+	number, err := strconv.Atoi(s[:len(s)-1])
+	letter := s[len(s)-1]
+	if err != nil || number < 1 || number > 12 || (letter != 'A' && letter != 'B') {
+		return NoKey, false
+	}
+	for k := Key(0); k < 24; k++ {
+		if k.camelotNumber() == number && k.Minor() == (letter == 'A') {
+			return k, true
+		}
+	}
+	return NoKey, false
+}
+
+// Analysis 是一首曲目的速度和调性
+type Analysis struct {
+	BPM float64 // 每分钟拍数，保留一位小数；没有明显节拍时为 0
+	Key Key
+}
+
+// biquad 是降采样前的低通滤波器
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func newLowpass(sampleRate, cutoff float64) biquad {
+	w := 2 * math.Pi * cutoff / sampleRate
+	alpha := math.Sin(w) / math.Sqrt2 // Q = 1/√2
+	cos := math.Cos(w)
+	a0 := 1 + alpha
+	return biquad{
+		b0: (1 - cos) / 2 / a0,
+		b1: (1 - cos) / a0,
+		b2: (1 - cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// Analyzer 逐块接收采样，边读边计算起音包络和色度，不需要把整首曲目留在内存里
+type Analyzer struct {
+	factor  int     // 降采样倍数
+	phase   int     // 当前降采样周期内已经跳过的采样数
+	rate    float64 // 降采样后的采样率
+	lowpass [2]biquad
+
+	mono      []float64 // 还没有处理完的降采样信号
+	base      int       // mono[0] 在整个降采样信号中的位置
+	onsetPos  int       // 下一帧起音分析的起点
+	chromaPos int       // 下一帧色度分析的起点
+
+	onsetWindow  []float64
+	chromaWindow []float64
+	onsetFFT     []complex128
+	chromaFFT    []complex128
+	previous     []float64 // 上一帧的对数幅度，用来计算频谱通量
+	chromaBins   []int     // 每个频点对应的音级（0 为 C），不在分析范围内时为 -1
+
+	envelope []float64
+	chroma   [12]float64
+}
+
+// NewAnalyzer 创建分析器，单声道文件被解码成左右相同的两路，直接取平均即可
+func NewAnalyzer(sr beep.SampleRate) *Analyzer {
+	factor := max(1, int(math.Round(float64(sr)/analysisRate)))
+	a := &Analyzer{
+		factor:       factor,
+		rate:         float64(sr) / float64(factor),
+		onsetWindow:  hannWindow(onsetWindow),
+		chromaWindow: hannWindow(chromaWindow),
+		onsetFFT:     make([]complex128, onsetWindow),
+		chromaFFT:    make([]complex128, chromaWindow),
+		previous:     make([]float64, onsetWindow/2),
+		chromaBins:   make([]int, chromaWindow/2),
+	}
+	// 两级二阶低通，截止在降采样后奈奎斯特频率的 90%，减少混叠
+	for i := range a.lowpass {
+		a.lowpass[i] = newLowpass(float64(sr), 0.45*a.rate)
+	}
+	for k := range a.chromaBins {
+		a.chromaBins[k] = -1
+		freq := float64(k) * a.rate / chromaWindow
+		if freq < chromaMinFreq || freq > chromaMaxFreq {
+			continue
+		}
+		midi := int(math.Round(12*math.Log2(freq/440) + 69))
+		a.chromaBins[k] = midi % 12
+	}
+	return a
+}
+
+func hannWindow(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+	}
+	return w
+}
+
+// Write 处理一批采样
+func (a *Analyzer) Write(samples [][2]float64) {
+	for _, s := range samples {
+		x := (s[0] + s[1]) / 2
+		for i := range a.lowpass {
+			x = a.lowpass[i].process(x)
+		}
+		a.phase++
+		if a.phase == a.factor {
+			a.phase = 0
+			a.mono = append(a.mono, x)
+		}
+	}
+
+	end := a.base + len(a.mono)
+	for a.onsetPos+onsetWindow <= end {
+		a.onsetFrame(a.mono[a.onsetPos-a.base:][:onsetWindow])
+		a.onsetPos += onsetHop
+	}
+	for a.chromaPos+chromaWindow <= end {
+		a.chromaFrame(a.mono[a.chromaPos-a.base:][:chromaWindow])
+		a.chromaPos += chromaHop
+	}
+	// 丢掉两种分析都已经用不到的部分
+	if drop := min(a.onsetPos, a.chromaPos) - a.base; drop >= chromaWindow {
+		a.mono = append(a.mono[:0], a.mono[drop:]...)
+		a.base += drop
+	}
+}
+
+func (a *Analyzer) onsetFrame(frame []float64) {
+	for i, x := range frame {
+		a.onsetFFT[i] = complex(x*a.onsetWindow[i], 0)
+	}
+	FFT(a.onsetFFT)
+	flux := 0.0
+	for k := 1; k < onsetWindow/2; k++ {
+		magnitude := math.Log1p(onsetCompression * cmplx.Abs(a.onsetFFT[k]))
+		if d := magnitude - a.previous[k]; d > 0 {
+			flux += d
+		}
+		a.previous[k] = magnitude
+	}
+	a.envelope = append(a.envelope, flux)
+}
+
+func (a *Analyzer) chromaFrame(frame []float64) {
+	for i, x := range frame {
+		a.chromaFFT[i] = complex(x*a.chromaWindow[i], 0)
+	}
+	FFT(a.chromaFFT)
+	for k, pitch := range a.chromaBins {
+		if pitch >= 0 {
+			a.chroma[pitch] += cmplx.Abs(a.chromaFFT[k])
+		}
+	}
+}
+
+// Result 返回到目前为止的分析结果
+func (a *Analyzer) Result() Analysis {
+	return Analysis{BPM: a.tempo(), Key: a.key()}
+}
+
+// tempo 在 60 - 200 BPM 范围内找起音包络自相关最强的周期
+func (a *Analyzer) tempo() float64 {
+	hop := onsetHop / a.rate // 每帧的秒数
+	n := len(a.envelope)
+	minLag := int(60 / (tempoMaxBPM * hop))
+	maxLag := int(math.Ceil(60 / (tempoMinBPM * hop)))
+	longest := tempoRefineBeats*(maxLag+1) + 1
+	if n < 2*longest {
+		return 0
+	}
+
+	// 减去局部平均并半波整流
+	prefix := make([]float64, n+1)
+	for i, v := range a.envelope {
+		prefix[i+1] = prefix[i] + v
+	}
+	half := max(1, int(onsetMeanWindow/hop/2))
+	novelty := make([]float64, n)
+	mean := 0.0
+	for i, v := range a.envelope {
+		lo, hi := max(0, i-half), min(n, i+half+1)
+		novelty[i] = max(0, v-(prefix[hi]-prefix[lo])/float64(hi-lo))
+		mean += novelty[i]
+	}
+	mean /= float64(n)
+	for i := range novelty {
+		novelty[i] -= mean
+	}
+
+	acf := make([]float64, longest+1)
+	for lag := range acf {
+		sum := 0.0
+		for i := 0; i+lag < n; i++ {
+			sum += novelty[i] * novelty[i+lag]
+		}
+		acf[lag] = sum / float64(n-lag)
+	}
+	if acf[0] <= 0 {
+		return 0
+	}
+
+	// 一拍的周期上有峰，两拍的周期上通常也有，两者一起算能减少把半拍当成一拍的情况
+	bestLag, bestScore := 0, math.Inf(-1)
+	for lag := max(1, minLag); lag <= maxLag; lag++ {
+		octaves := math.Log2(60 / (float64(lag) * hop) / tempoPriorBPM)
+		score := (acf[lag] + 0.5*acf[2*lag]) * math.Exp(-0.5*octaves*octaves)
+		if score > bestScore {
+			bestLag, bestScore = lag, score
+		}
+	}
+	if bestScore <= 0 {
+		return 0
+	}
+
+	// 在 4 拍附近找最高的峰，再用抛物线插值得到小数周期
+	center := tempoRefineBeats * bestLag
+	peak := center
+	for lag := center - tempoRefineBeats; lag <= center+tempoRefineBeats; lag++ {
+		if acf[lag] > acf[peak] {
+			peak = lag
+		}
+	}
+	period := float64(peak)
+	if y0, y1, y2 := acf[peak-1], acf[peak], acf[peak+1]; y0-2*y1+y2 < 0 {
+		period += 0.5 * (y0 - y2) / (y0 - 2*y1 + y2)
+	}
+	bpm := 60 / (period / tempoRefineBeats * hop)
+	return math.Round(bpm*10) / 10
+}
+
+// key 用整首曲目的色度向量和 24 个调性轮廓计算相关系数，取最高的一个
+func (a *Analyzer) key() Key {
+	total := 0.0
+	for _, v := range a.chroma {
+		total += v
+	}
+	if total == 0 {
+		return NoKey
+	}
+	best, bestR := NoKey, math.Inf(-1)
+	for tonic := 0; tonic < 12; tonic++ {
+		var rotated [12]float64
+		for i := range rotated {
+			rotated[i] = a.chroma[(tonic+i)%12]
+		}
+		if r := correlation(rotated, majorProfile); r > bestR {
+			best, bestR = Key(tonic), r
+		}
+		if r := correlation(rotated, minorProfile); r > bestR {
+			best, bestR = Key(tonic+12), r
+		}
+	}
+	return best
+}
+
+// correlation 返回皮尔逊相关系数
+func correlation(x, y [12]float64) float64 {
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx /= 12
+	my /= 12
+	var sxy, sxx, syy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
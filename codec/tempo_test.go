@@ -0,0 +1,106 @@
+package codec
+
+import "testing"
+
+func TestCamelot(t *testing.T) {
+	tests := []struct {
+		name    string
+		camelot string
+	}{
+		{"Abm", "1A"}, {"B", "1B"},
+		{"Ebm", "2A"}, {"F#", "2B"},
+		{"Bbm", "3A"}, {"C#", "3B"},
+		{"Fm", "4A"}, {"Ab", "4B"},
+		{"Cm", "5A"}, {"Eb", "5B"},
+		{"Gm", "6A"}, {"Bb", "6B"},
+		{"Dm", "7A"}, {"F", "7B"},
+		{"Am", "8A"}, {"C", "8B"},
+		{"Em", "9A"}, {"G", "9B"},
+		{"Bm", "10A"}, {"D", "10B"},
+		{"F#m", "11A"}, {"A", "11B"},
+		{"C#m", "12A"}, {"E", "12B"},
+	}
+	seen := make(map[Key]bool)
+	for order, tt := range tests {
+		k, ok := ParseCamelot(tt.camelot)
+		if !ok {
+			t.Errorf("ParseCamelot(%q) failed", tt.camelot)
+			continue
+		}
+		if k.String() != tt.name || k.Camelot() != tt.camelot || k.CamelotOrder() != order {
+			t.Errorf("%s: got %s %s order %d, want %s %s order %d",
+				tt.camelot, k, k.Camelot(), k.CamelotOrder(), tt.name, tt.camelot, order)
+		}
+		seen[k] = true
+	}
+	if len(seen) != 24 {
+		t.Errorf("camelot wheel covers %d keys, want 24", len(seen))
+	}
+	if NoKey.String() != "" || NoKey.Camelot() != "" || NoKey.CamelotOrder() != -1 {
+		t.Error("NoKey should have no name, no camelot code and order -1")
+	}
+}
+
+func TestParseCamelot(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"8A", "Am", true},
+		{"12b", "E", true},
+		{" 1a ", "Abm", true},
+		{"", "", false},
+		{"A", "", false},
+		{"8", "", false},
+		{"0A", "", false},
+		{"13B", "", false},
+		{"8C", "", false},
+		{"-1A", "", false},
+		{"AB", "", false},
+		{"120-130", "", false},
+	}
+	for _, tt := range tests {
+		k, ok := ParseCamelot(tt.input)
+		if ok != tt.ok || k.String() != tt.want {
+			t.Errorf("ParseCamelot(%q) = %q, %v, want %q, %v", tt.input, k, ok, tt.want, tt.ok)
+		}
+		if !ok && k != NoKey {
+			t.Errorf("ParseCamelot(%q) = %d on failure, want NoKey", tt.input, k)
+		}
+	}
+}
+
+func TestKeyCompatible(t *testing.T) {
+	key := func(s string) Key {
+		k, ok := ParseCamelot(s)
+		if !ok {
+			t.Fatalf("bad camelot code %q", s)
+		}
+		return k
+	}
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"8A", "8A", true},
+		{"8A", "9A", true},
+		{"8A", "7A", true},
+		{"8A", "8B", true},  // 关系大小调
+		{"12B", "1B", true}, // 调性轮首尾相接
+		{"1A", "12A", true},
+		{"8A", "10A", false},
+		{"8A", "9B", false},
+		{"8B", "7A", false},
+		{"3B", "9B", false},
+	}
+	for _, tt := range tests {
+		a, b := key(tt.a), key(tt.b)
+		if a.Compatible(b) != tt.want || b.Compatible(a) != tt.want {
+			t.Errorf("%s compatible with %s = %v, want %v", tt.a, tt.b, a.Compatible(b), tt.want)
+		}
+	}
+	if NoKey.Compatible(NoKey) || key("8A").Compatible(NoKey) {
+		t.Error("NoKey should not be compatible with anything")
+	}
+}
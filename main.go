@@ -1,9 +1,41 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"music-cli/player"
+	"os"
 )
 
 func main() {
+	// 子命令不进入交互界面
+	if len(os.Args) > 1 {
+		var run func([]string) error
+		switch os.Args[1] {
+		case "export":
+			run = player.Export
+		case "loudness":
+			run = player.Loudness
+		case "analyze":
+			run = player.Analyze
+		}
+		if run != nil {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "错误:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	outputSpec := flag.String("output", "speaker", "音频输出：speaker、null、null-fast、wav=FILE、wav-fast=FILE")
+	flag.Parse()
+
+	out, err := player.ParseOutput(*outputSpec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	player.SetOutput(out)
 	player.PageController()
 }
@@ -0,0 +1,127 @@
+package player
+
+import (
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// abLoop 紧贴在解码器之上，设置了 A、B 两点后播放到 B 就跳回 A
+// 跳转在音频线程里按采样完成，循环处没有延迟
+type abLoop struct {
+	streamer beep.StreamSeeker
+	start    int // A 点采样位置，-1 表示未设置
+	end      int // B 点采样位置，-1 表示未设置
+	resync   chan struct{}
+}
+
+func newABLoop(s beep.StreamSeeker, resync chan struct{}) *abLoop {
+	return &abLoop{streamer: s, start: -1, end: -1, resync: resync}
+}
+
+// active 返回是否正在 A-B 循环
+func (l *abLoop) active() bool {
+	return l.start >= 0 && l.end > l.start
+}
+
+func (l *abLoop) Stream(samples [][2]float64) (n int, ok bool) {
+	if !l.active() {
+		return l.streamer.Stream(samples)
+	}
+	for n < len(samples) {
+		pos := l.streamer.Position()
+		if pos >= l.end {
+			if err := l.streamer.Seek(l.start); err != nil {
+				return n, n > 0
+			}
+			// 通知歌词在循环后重新定位
+			select {
+			case l.resync <- struct{}{}:
+			default:
+			}
+			continue
+		}
+		want := len(samples) - n
+		if left := l.end - pos; want > left {
+			want = left
+		}
+		sn, sok := l.streamer.Stream(samples[n : n+want])
+		n += sn
+		if !sok || sn == 0 {
+			return n, n > 0
+		}
+	}
+	return n, true
+}
+
+func (l *abLoop) Err() error {
+	return l.streamer.Err()
+}
+
+// setLoopA 把当前位置设为 A 点，同时清除 B 点
+func (p *Player) setLoopA() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ab == nil {
+		return
+	}
+	output.Lock()
+	p.ab.start = p.streamer.Position()
+	p.ab.end = -1
+	output.Unlock()
+}
+
+// setLoopB 把当前位置设为 B 点并立即跳回 A 点开始循环，B 不在 A 之后时忽略
+func (p *Player) setLoopB() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ab == nil {
+		return
+	}
+	output.Lock()
+	defer output.Unlock()
+	pos := p.streamer.Position()
+	if p.ab.start < 0 || pos <= p.ab.start {
+		return
+	}
+	p.ab.end = pos
+	if err := p.streamer.Seek(p.ab.start); err != nil {
+		return
+	}
+	select {
+	case p.resync <- struct{}{}:
+	default:
+	}
+}
+
+// clearLoop 清除 A、B 两点，恢复正常播放
+func (p *Player) clearLoop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ab == nil {
+		return
+	}
+	output.Lock()
+	p.ab.start, p.ab.end = -1, -1
+	output.Unlock()
+}
+
+// loopPoints 返回 A、B 两点的时间，未设置的点为 -1
+func (p *Player) loopPoints() (time.Duration, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a, b := time.Duration(-1), time.Duration(-1)
+	if p.ab == nil {
+		return a, b
+	}
+	output.Lock()
+	start, end := p.ab.start, p.ab.end
+	output.Unlock()
+	if start >= 0 {
+		a = p.format.SampleRate.D(start)
+	}
+	if end >= 0 {
+		b = p.format.SampleRate.D(end)
+	}
+	return a, b
+}
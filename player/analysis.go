@@ -0,0 +1,388 @@
+package player
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"music-cli/codec"
+	"music-cli/utils"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+const analyzeUsage = `用法：music-cli analyze [选项] [目录或文件...]
+
+估计每首曲目的速度（BPM）和调性，结果保存在配置目录的 analysis.json 中，
+目录列表和播放界面会显示出来，也可以在目录中按速度或调性排序、筛选后播放。
+不指定输入时扫描当前目录（包括子目录），已经分析过且没有修改的文件直接使用缓存。
+
+选项：
+`
+
+const analysisChunk = 8192
+
+var errAnalysisCanceled = errors.New("analysis canceled")
+
+// analysisResult 是一首曲目的分析结果
+type analysisResult struct {
+	path     string
+	analysis codec.Analysis
+	err      error
+}
+
+// Analyze 是 music-cli analyze 子命令
+func Analyze(args []string) error {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), analyzeUsage)
+		fs.PrintDefaults()
+	}
+	workers := fs.Int("j", runtime.NumCPU(), "同时分析的文件数")
+	force := fs.Bool("force", false, "忽略缓存，重新分析所有文件")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if *workers < 1 {
+		return fmt.Errorf("无效的并发数：%d", *workers)
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"."}
+	}
+	var paths []string
+	for _, input := range inputs {
+		if _, err := os.Stat(input); err != nil {
+			return err
+		}
+		// CUE 文件展开成其中的音轨，分别分析
+		files, err := utils.WalkDir(input)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, files...)
+	}
+	if len(paths) == 0 {
+		return errors.New("没有找到可以分析的音频文件")
+	}
+
+	// 有缓存的曲目不会调用 progress，总数只算真正要分析的
+	results, err := analyzeAll(paths, *workers, *force, printProgress(pendingAnalysis(paths, *force)))
+	if err != nil {
+		return err
+	}
+	return writeAnalysisReport(os.Stdout, results)
+}
+
+// analyzeAll 返回每首曲目的速度和调性，没有缓存的先分析再写入缓存
+// progress 只对真正分析了的曲目调用
+func analyzeAll(paths []string, workers int, force bool, progress func(string, error)) ([]analysisResult, error) {
+	results := make([]analysisResult, len(paths))
+	var missing []string
+	var indexes []int
+	for i, path := range paths {
+		results[i] = analysisResult{path: path, analysis: codec.Analysis{Key: codec.NoKey}}
+		if a, ok := utils.LookupAnalysis(path); ok && !force {
+			results[i].analysis = a
+			continue
+		}
+		missing = append(missing, path)
+		indexes = append(indexes, i)
+	}
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	forEachPath(missing, workers, func(j int) error {
+		r := &results[indexes[j]]
+		r.analysis, r.err = analyzeTrack(r.path, nil)
+		if r.err != nil {
+			r.analysis = codec.Analysis{Key: codec.NoKey}
+			return r.err
+		}
+		return utils.StoreAnalysis(r.path, r.analysis)
+	}, progress)
+	return results, utils.SaveAnalysis()
+}
+
+// pendingAnalysis 返回 analyzeAll 需要真正分析的曲目数
+func pendingAnalysis(paths []string, force bool) int {
+	if force {
+		return len(paths)
+	}
+	n := 0
+	for _, path := range paths {
+		if _, ok := utils.LookupAnalysis(path); !ok {
+			n++
+		}
+	}
+	return n
+}
+
+// analyzeTrack 用单独的解码器把曲目完整读一遍，done 关闭时放弃
+func analyzeTrack(path string, done chan struct{}) (codec.Analysis, error) {
+	file := path
+	var cue *utils.CueTrack
+	if _, _, ok := utils.SplitCueTrackPath(path); ok {
+		track, err := utils.LoadCueTrack(path)
+		if err != nil {
+			return codec.Analysis{}, err
+		}
+		cue, file = track, track.File
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return codec.Analysis{}, err
+	}
+	decoder, format, err := codec.Decode(file, f)
+	if err != nil {
+		f.Close()
+		return codec.Analysis{}, err
+	}
+	defer decoder.Close()
+	var streamer beep.Streamer = decoder
+	if cue != nil {
+		bounded, err := newSection(decoder, format, cue)
+		if err != nil {
+			return codec.Analysis{}, err
+		}
+		streamer = bounded
+	}
+
+	analyzer := codec.NewAnalyzer(format.SampleRate)
+	buf := make([][2]float64, analysisChunk)
+	for {
+		select {
+		case <-done:
+			return codec.Analysis{}, errAnalysisCanceled
+		default:
+		}
+		n, ok := streamer.Stream(buf)
+		analyzer.Write(buf[:n])
+		if !ok {
+			break
+		}
+	}
+	if err := streamer.Err(); err != nil {
+		return codec.Analysis{}, err
+	}
+	return analyzer.Result(), nil
+}
+
+// writeAnalysisReport 按目录分组输出每首曲目的速度和调性
+func writeAnalysisReport(out io.Writer, results []analysisResult) error {
+	var w *tabwriter.Writer
+	dir := ""
+	for _, r := range results {
+		if d := filepath.Dir(r.path); w == nil || d != dir {
+			if w != nil {
+				if err := w.Flush(); err != nil {
+					return err
+				}
+			}
+			dir = d
+			fmt.Fprintf(out, "\n%s\n", dir)
+			w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "  文件\tBPM\t调性\tCamelot")
+		}
+		name := utils.TrackName(r.path)
+		if r.err != nil {
+			fmt.Fprintf(w, "  %s\t错误：%v\n", name, r.err)
+			continue
+		}
+		bpm := "-"
+		if r.analysis.BPM > 0 {
+			bpm = strconv.FormatFloat(r.analysis.BPM, 'f', 1, 64)
+		}
+		key, camelot := r.analysis.Key.String(), r.analysis.Key.Camelot()
+		if key == "" {
+			key, camelot = "-", "-"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", name, bpm, key, camelot)
+	}
+	if w == nil {
+		return nil
+	}
+	return w.Flush()
+}
+
+// loadAnalysis 在后台读取或计算当前曲目的速度和调性，完成后更新标题行
+func (p *Player) loadAnalysis(done chan struct{}) {
+	path := p.path
+	a, ok := utils.LookupAnalysis(path)
+	if !ok {
+		var err error
+		a, err = analyzeTrack(path, done)
+		if err != nil {
+			return
+		}
+		if utils.StoreAnalysis(path, a) == nil {
+			_ = utils.SaveAnalysis()
+		}
+	}
+	p.analysis.Store(&a)
+
+	select {
+	case <-done:
+		return
+	default:
+	}
+	p.printTitle()
+}
+
+// printTitle 在第一行居中显示曲目信息，知道速度和调性时一起显示
+func (p *Player) printTitle() {
+	title := fmt.Sprintf("[%d]: %s - %s", p.id, p.metadata.Artist(), p.metadata.Title())
+	if a := p.analysis.Load(); a != nil {
+		if text := utils.FormatAnalysis(*a); text != "" {
+			title += "  " + text
+		}
+	}
+	printMu.Lock()
+	defer printMu.Unlock()
+	fmt.Print("\033[1;1H\033[2K" + utils.Center(title))
+}
+
+// trackFilter 是按速度或调性筛选曲目的条件
+type trackFilter struct {
+	minBPM, maxBPM float64   // 都为 0 时不限速度
+	key            codec.Key // 只保留可以和它和谐混音的调性，NoKey 表示不限
+}
+
+// parseTrackFilter 解析 120-130（速度范围）和 8A（Camelot 调性）形式的条件，可以同时给出
+func parseTrackFilter(args []string) (trackFilter, bool) {
+	filter := trackFilter{key: codec.NoKey}
+	for _, arg := range args {
+		if key, ok := codec.ParseCamelot(arg); ok {
+			filter.key = key
+			continue
+		}
+		lo, hi, found := strings.Cut(arg, "-")
+		if !found {
+			return filter, false
+		}
+		minBPM, err1 := strconv.ParseFloat(lo, 64)
+		maxBPM, err2 := strconv.ParseFloat(hi, 64)
+		if err1 != nil || err2 != nil || minBPM <= 0 || minBPM > maxBPM {
+			return filter, false
+		}
+		filter.minBPM, filter.maxBPM = minBPM, maxBPM
+	}
+	return filter, true
+}
+
+func (f trackFilter) match(a codec.Analysis) bool {
+	if f.maxBPM > 0 && (a.BPM < f.minBPM || a.BPM > f.maxBPM) {
+		return false
+	}
+	return f.key == codec.NoKey || f.key.Compatible(a.Key)
+}
+
+// handleOrderedPlay 处理按速度或调性排序、筛选后播放的菜单命令：
+// 0b / ab 按速度，0k / ak 按调性（Camelot 顺序），0r / ar 后面加条件时随机播放符合条件的曲目，
+// 第一个字符 0 表示当前页，a 表示递归整个目录，条件例如 ab 120-130、ak 8A、ar 125-135 8A
+func handleOrderedPlay(root string, page int, input string, files []string) (bool, error) {
+	fields := strings.Fields(strings.ToLower(input))
+	if len(fields) == 0 || len(fields[0]) != 2 {
+		return false, nil
+	}
+	scope, order := fields[0][0], fields[0][1]
+	if (scope != '0' && scope != 'a') || (order != 'b' && order != 'k' && order != 'r') {
+		return false, nil
+	}
+	if order == 'r' && len(fields) == 1 {
+		// 不带条件的 0r / ar 是原来的随机播放
+		return false, nil
+	}
+	filter, ok := parseTrackFilter(fields[1:])
+	if !ok {
+		return false, nil
+	}
+
+	paths := files
+	if scope == 'a' {
+		var err error
+		paths, err = utils.WalkDir(root)
+		if err != nil {
+			fmt.Println("错误:", err)
+			return true, err
+		}
+	}
+
+	total, finished := pendingAnalysis(paths, false), 0
+	results, err := analyzeAll(paths, runtime.NumCPU(), false, func(path string, _ error) {
+		finished++
+		fmt.Printf("\r\033[2K正在分析速度和调性 [%d/%d] %s", finished, total, utils.TrackName(path))
+	})
+	if total > 0 {
+		fmt.Println()
+	}
+	if err != nil {
+		fmt.Println("保存分析结果失败:", err)
+	}
+
+	var tracks []analysisResult
+	for _, r := range results {
+		if filter.match(r.analysis) {
+			tracks = append(tracks, r)
+		}
+	}
+	if len(tracks) == 0 {
+		fmt.Print("没有符合条件的曲目")
+		time.Sleep(1 * time.Second)
+		pageChannel <- pageChange{signal: toMenuSignal, root: root, page: page}
+		return true, nil
+	}
+
+	// 速度或调性未知的曲目排在最后
+	switch order {
+	case 'b':
+		slices.SortStableFunc(tracks, func(a, b analysisResult) int {
+			return cmp.Compare(bpmOrder(a.analysis), bpmOrder(b.analysis))
+		})
+	case 'k':
+		slices.SortStableFunc(tracks, func(a, b analysisResult) int {
+			return cmp.Or(
+				cmp.Compare(keyOrder(a.analysis), keyOrder(b.analysis)),
+				cmp.Compare(bpmOrder(a.analysis), bpmOrder(b.analysis)),
+			)
+		})
+	}
+	sorted := make([]string, len(tracks))
+	for i, t := range tracks {
+		sorted[i] = t.path
+	}
+	players := getPlayerList(sorted)
+	if order == 'r' {
+		players = randomPlayer(players)
+	}
+	handlePlayInput(root, 0, page, players)
+	return true, nil
+}
+
+func bpmOrder(a codec.Analysis) float64 {
+	if a.BPM <= 0 {
+		return 1e9
+	}
+	return a.BPM
+}
+
+func keyOrder(a codec.Analysis) int {
+	if order := a.Key.CamelotOrder(); order >= 0 {
+		return order
+	}
+	return 1 << 30
+}
@@ -0,0 +1,37 @@
+package player
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalysisProgressTotal(t *testing.T) {
+	var paths []string
+	for _, name := range []string{"a.wav", "b.wav", "c.wav"} {
+		paths = append(paths, writeTone(t, name, time.Second, [2]float64{0.5, 0.5}))
+	}
+	// 先分析第一首，之后它直接使用缓存
+	if _, err := analyzeAll(paths[:1], 1, false, func(string, error) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		force bool
+		want  int
+	}{
+		{false, 2},
+		{true, 3},
+	}
+	for _, tt := range tests {
+		total := pendingAnalysis(paths, tt.force)
+		calls := 0
+		results, err := analyzeAll(paths, 2, tt.force, func(string, error) { calls++ })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != tt.want || calls != total || len(results) != len(paths) {
+			t.Errorf("force=%v: total %d, progress called %d times, %d results, want %d, %d, %d",
+				tt.force, total, calls, len(results), tt.want, tt.want, len(paths))
+		}
+	}
+}
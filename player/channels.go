@@ -0,0 +1,126 @@
+package player
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+const (
+	balanceStep = 0.1
+	maxBalance  = 1.0
+)
+
+// channelSettings 是声道设置：左右平衡、单声道混音和左右互换
+type channelSettings struct {
+	Balance float64 `json:"balance"` // -1 只有左声道，0 居中，1 只有右声道
+	Mono    bool    `json:"mono"`
+	Swap    bool    `json:"swap"`
+}
+
+// channelMixer 是声道处理阶段，依次做左右互换、单声道混音和平衡
+// 平衡放在混音之后，单声道时也可以只从一侧耳机出声
+type channelMixer struct {
+	streamer beep.Streamer
+	settings channelSettings
+}
+
+// newChannelMixer 按当前保存的声道设置创建声道处理阶段
+func newChannelMixer(s beep.Streamer) *channelMixer {
+	lockState()
+	defer stateMu.Unlock()
+	return &channelMixer{streamer: s, settings: state.Channels}
+}
+
+func (c *channelMixer) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = c.streamer.Stream(samples)
+	s := c.settings
+	if s == (channelSettings{}) {
+		return n, ok
+	}
+	// 偏向一侧时只衰减另一侧，居中时两侧都不变
+	left, right := 1.0, 1.0
+	if s.Balance > 0 {
+		left = 1 - s.Balance
+	} else if s.Balance < 0 {
+		right = 1 + s.Balance
+	}
+	for i := range samples[:n] {
+		l, r := samples[i][0], samples[i][1]
+		if s.Swap {
+			l, r = r, l
+		}
+		if s.Mono {
+			l = (l + r) / 2
+			r = l
+		}
+		samples[i][0], samples[i][1] = l*left, r*right
+	}
+	return n, ok
+}
+
+func (c *channelMixer) Err() error {
+	return c.streamer.Err()
+}
+
+// setChannels 修改声道设置，保存到状态文件并应用到正在播放和已排队的曲目
+func setChannels(settings channelSettings, players ...*Player) {
+	settings.Balance = math.Round(max(-maxBalance, min(maxBalance, settings.Balance))/balanceStep) * balanceStep
+	lockState()
+	state.Channels = settings
+	_ = saveState()
+	stateMu.Unlock()
+
+	for _, p := range players {
+		p.applyChannels(settings)
+	}
+}
+
+func (p *Player) applyChannels(settings channelSettings) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.channels == nil {
+		return
+	}
+	output.Lock()
+	p.channels.settings = settings
+	output.Unlock()
+}
+
+// balanceText 把平衡值显示成 “L30” “R50” 或 “居中”
+func balanceText(balance float64) string {
+	percent := int(math.Round(math.Abs(balance) * 100))
+	switch {
+	case percent == 0:
+		return "居中"
+	case balance < 0:
+		return fmt.Sprintf("L%d", percent)
+	}
+	return fmt.Sprintf("R%d", percent)
+}
+
+func channelsText() string {
+	lockState()
+	s := state.Channels
+	stateMu.Unlock()
+
+	text := ""
+	if s.Mono {
+		text += " 单声道"
+	}
+	if s.Swap {
+		text += " 左右互换"
+	}
+	if balanceText(s.Balance) != "居中" {
+		text += " 平衡 " + balanceText(s.Balance)
+	}
+	if text == "" {
+		return ""
+	}
+	return text[1:]
+}
@@ -0,0 +1,71 @@
+package player
+
+// audioPanel 是播放界面中的音频设置面板
+// ↑/↓ 选择项目，←/→ 调节平衡或切换开关，0 把平衡归中，o 或 q 关闭，修改立即生效
+type audioPanel struct {
+	row      int // 0 平衡，1 单声道，2 左右互换
+	settings channelSettings
+}
+
+func newAudioPanel() *audioPanel {
+	lockState()
+	defer stateMu.Unlock()
+	return &audioPanel{settings: state.Channels}
+}
+
+func (p *audioPanel) handleKey(k key, players []*Player) bool {
+	switch k {
+	case 'o', 'O', 'q', 'Q', keyEnter, '\n':
+		return false
+	case keyUp, 'k':
+		p.row = (p.row + 2) % 3
+	case keyDown, 'j':
+		p.row = (p.row + 1) % 3
+	case keyLeft, 'h':
+		p.adjust(-1, players)
+	case keyRight, 'l', ' ':
+		p.adjust(1, players)
+	case '0':
+		p.settings.Balance = 0
+		setChannels(p.settings, players...)
+	}
+	return true
+}
+
+func (p *audioPanel) adjust(delta int, players []*Player) {
+	switch p.row {
+	case 0:
+		p.settings.Balance += float64(delta) * balanceStep
+	case 1:
+		p.settings.Mono = !p.settings.Mono
+	case 2:
+		p.settings.Swap = !p.settings.Swap
+	}
+	setChannels(p.settings, players...)
+	lockState()
+	p.settings = state.Channels
+	stateMu.Unlock()
+}
+
+func (p *audioPanel) lines() []string {
+	onOff := func(on bool) string {
+		if on {
+			return "开"
+		}
+		return "关"
+	}
+	items := []string{
+		"平衡:     " + balanceText(p.settings.Balance),
+		"单声道:   " + onOff(p.settings.Mono),
+		"左右互换: " + onOff(p.settings.Swap),
+	}
+	lines := []string{"  \x1b[1m音频设置\x1b[0m"}
+	for i, item := range items {
+		marker := "  "
+		if i == p.row {
+			marker = "\x1b[34m➣ "
+		}
+		lines = append(lines, "  "+marker+"◀ "+item+" ▶\x1b[0m")
+	}
+	return append(lines, "  ↑/↓ 选择  ←/→ 修改  0 平衡归中  o 关闭")
+}
@@ -0,0 +1,59 @@
+package player
+
+import (
+	"fmt"
+	"time"
+)
+
+// crossfadeSteps 是按键循环切换的淡入淡出时长，0 表示关闭
+var crossfadeSteps = []time.Duration{0, 2 * time.Second, 5 * time.Second, 10 * time.Second}
+
+// crossfade 是本次运行中相邻曲目交叉淡入淡出的时长，只在输出后端的锁内读写
+var crossfade time.Duration
+
+// cycleCrossfade 切换到下一档淡入淡出时长
+func cycleCrossfade() {
+	output.Lock()
+	defer output.Unlock()
+	for i, d := range crossfadeSteps {
+		if d == crossfade {
+			crossfade = crossfadeSteps[(i+1)%len(crossfadeSteps)]
+			return
+		}
+	}
+	crossfade = 0
+}
+
+func crossfadeText() string {
+	output.Lock()
+	d := crossfade
+	output.Unlock()
+	if d == 0 {
+		return ""
+	}
+	return fmt.Sprintf("淡入淡出 %ds", int(d.Seconds()))
+}
+
+// sameAlbum 判断两首曲目是否属于同一张专辑，同专辑的曲目之间直接无缝衔接，不做淡入淡出
+func sameAlbum(a, b *Player) bool {
+	if a == nil || b == nil || a.metadata == nil || b.metadata == nil {
+		return false
+	}
+	if _, ok := a.metadata.(*defaultMetadata); ok {
+		return false
+	}
+	if _, ok := b.metadata.(*defaultMetadata); ok {
+		return false
+	}
+	if a.metadata.Album() == "" || a.metadata.Album() != b.metadata.Album() {
+		return false
+	}
+	return albumArtist(a) == albumArtist(b)
+}
+
+func albumArtist(p *Player) string {
+	if artist := p.metadata.AlbumArtist(); artist != "" {
+		return artist
+	}
+	return p.metadata.Artist()
+}
@@ -0,0 +1,86 @@
+package player
+
+import (
+	"music-cli/utils"
+
+	"github.com/dhowden/tag"
+	"github.com/faiface/beep"
+)
+
+// section 把整轨音频中 [start, end) 的一段当作独立的曲目，位置和长度都相对于 start
+type section struct {
+	beep.StreamSeekCloser
+	start, end int
+}
+
+func newSection(s beep.StreamSeekCloser, format beep.Format, track *utils.CueTrack) (*section, error) {
+	start := format.SampleRate.N(track.Start)
+	end := s.Len()
+	if track.End > 0 && format.SampleRate.N(track.End) < end {
+		end = format.SampleRate.N(track.End)
+	}
+	if start > end {
+		start = end
+	}
+	if err := s.Seek(start); err != nil {
+		return nil, err
+	}
+	return &section{StreamSeekCloser: s, start: start, end: end}, nil
+}
+
+func (s *section) Stream(samples [][2]float64) (n int, ok bool) {
+	left := s.end - s.StreamSeekCloser.Position()
+	if left <= 0 {
+		return 0, false
+	}
+	if len(samples) > left {
+		samples = samples[:left]
+	}
+	return s.StreamSeekCloser.Stream(samples)
+}
+
+func (s *section) Len() int {
+	return s.end - s.start
+}
+
+func (s *section) Position() int {
+	return s.StreamSeekCloser.Position() - s.start
+}
+
+func (s *section) Seek(p int) error {
+	return s.StreamSeekCloser.Seek(s.start + p)
+}
+
+// cueMetadata 用 CUE 文件中的标题和艺术家覆盖整轨文件的标签
+type cueMetadata struct {
+	tag.Metadata
+	track *utils.CueTrack
+}
+
+func (m *cueMetadata) Title() string {
+	return m.track.Title
+}
+
+func (m *cueMetadata) Artist() string {
+	if m.track.Performer == "" {
+		return m.Metadata.Artist()
+	}
+	return m.track.Performer
+}
+
+func (m *cueMetadata) Album() string {
+	if m.track.Album == "" {
+		return m.Metadata.Album()
+	}
+	return m.track.Album
+}
+
+func (m *cueMetadata) Track() (int, int) {
+	_, total := m.Metadata.Track()
+	return m.track.Number, total
+}
+
+// 整轨文件内嵌的歌词对应整张专辑，不适用于单条音轨
+func (m *cueMetadata) Lyrics() string {
+	return ""
+}
@@ -0,0 +1,253 @@
+package player
+
+import (
+	"encoding/json"
+	"math"
+	"music-cli/utils"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/faiface/beep"
+)
+
+const (
+	eqBandCount   = 10
+	eqMaxGain     = 12.0
+	eqQ           = 1.41 // 约一个倍频程的带宽
+	eqPresetsFile = "eq_presets.json"
+)
+
+// eqFrequencies 是 10 个频段的中心频率（Hz）
+var eqFrequencies = [eqBandCount]float64{31, 62, 125, 250, 500, 1000, 2000, 4000, 8000, 16000}
+
+// eqPreset 是一组命名的频段增益（dB）
+type eqPreset struct {
+	Name  string               `json:"name"`
+	Gains [eqBandCount]float64 `json:"gains"`
+}
+
+// builtinEQPresets 是内置预设，第一个必须是平直
+var builtinEQPresets = []eqPreset{
+	{Name: "flat"},
+	{Name: "bass boost", Gains: [eqBandCount]float64{6, 5, 4, 2, 0, 0, 0, 0, 0, 0}},
+	{Name: "treble boost", Gains: [eqBandCount]float64{0, 0, 0, 0, 0, 1, 2, 4, 5, 6}},
+	{Name: "vocal", Gains: [eqBandCount]float64{-3, -2, -1, 1, 3, 4, 3, 1, 0, -1}},
+	{Name: "rock", Gains: [eqBandCount]float64{4, 3, 2, 0, -1, -1, 1, 2, 3, 4}},
+	{Name: "pop", Gains: [eqBandCount]float64{-1, 0, 2, 3, 4, 3, 1, 0, -1, -1}},
+	{Name: "jazz", Gains: [eqBandCount]float64{3, 2, 1, 2, -1, -1, 0, 1, 2, 3}},
+	{Name: "classical", Gains: [eqBandCount]float64{4, 3, 2, 1, 0, 0, 0, 1, 2, 3}},
+	{Name: "electronic", Gains: [eqBandCount]float64{5, 4, 1, 0, -2, 1, 0, 1, 4, 5}},
+	{Name: "loudness", Gains: [eqBandCount]float64{6, 4, 0, 0, -2, 0, -1, -3, 3, 2}},
+}
+
+// biquad 是 RBJ Audio EQ Cookbook 中的峰值滤波器，系数已按 a0 归一化
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     [2]float64 // 左右声道各自的历史采样
+}
+
+// setPeaking 只修改系数，历史采样保持不变，播放中调节增益不会有爆音
+// 0 dB 时系数正好是直通（b0 = 1，b1 = a1，b2 = a2）
+func (f *biquad) setPeaking(f0, gain float64, sr beep.SampleRate) {
+	a := math.Pow(10, gain/40)
+	w0 := 2 * math.Pi * f0 / float64(sr)
+	alpha := math.Sin(w0) / (2 * eqQ)
+	cos := math.Cos(w0)
+	a0 := 1 + alpha/a
+	f.b0 = (1 + alpha*a) / a0
+	f.b1 = -2 * cos / a0
+	f.b2 = (1 - alpha*a) / a0
+	f.a1 = -2 * cos / a0
+	f.a2 = (1 - alpha/a) / a0
+}
+
+func (f *biquad) process(samples [][2]float64) {
+	for i := range samples {
+		for c := 0; c < 2; c++ {
+			x := samples[i][c]
+			y := f.b0*x + f.b1*f.x1[c] + f.b2*f.x2[c] - f.a1*f.y1[c] - f.a2*f.y2[c]
+			f.x2[c], f.x1[c] = f.x1[c], x
+			f.y2[c], f.y1[c] = f.y1[c], y
+			samples[i][c] = y
+		}
+	}
+}
+
+// settled 判断输出是否已经和输入一致（直通时残留的振荡衰减完了）
+func (f *biquad) settled() bool {
+	const epsilon = 1e-9
+	for c := 0; c < 2; c++ {
+		if math.Abs(f.x1[c]-f.y1[c]) > epsilon || math.Abs(f.x2[c]-f.y2[c]) > epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// bypass 跳过 0 dB 的频段，只记下最后两个采样作为历史，之后提升这个频段时从当前信号接着滤波
+func (f *biquad) bypass(samples [][2]float64) {
+	for _, sample := range samples[max(0, len(samples)-2):] {
+		f.x2, f.x1 = f.x1, sample
+		f.y2, f.y1 = f.y1, sample
+	}
+}
+
+// equalizer 是 10 段图形均衡器阶段，工作在曲目自己的采样率上
+// 每个频段固定一个滤波器，增益或采样率变化时只重新计算系数
+type equalizer struct {
+	streamer   beep.Streamer
+	sampleRate beep.SampleRate
+	gains      [eqBandCount]float64
+
+	designedRate  beep.SampleRate
+	designedGains [eqBandCount]float64
+	filters       [eqBandCount]biquad
+	usable        [eqBandCount]bool // 低于奈奎斯特频率的频段才能实现
+}
+
+// newEqualizer 按当前均衡器设置创建均衡器阶段
+func newEqualizer(s beep.Streamer, sr beep.SampleRate) *equalizer {
+	lockState()
+	gains := state.EQ.Gains
+	stateMu.Unlock()
+	return &equalizer{streamer: s, sampleRate: sr, gains: gains}
+}
+
+func (e *equalizer) design() {
+	for i, gain := range e.gains {
+		e.usable[i] = eqFrequencies[i] < float64(e.sampleRate)/2
+		if e.usable[i] {
+			e.filters[i].setPeaking(eqFrequencies[i], gain, e.sampleRate)
+		}
+	}
+	e.designedRate = e.sampleRate
+	e.designedGains = e.gains
+}
+
+func (e *equalizer) Stream(samples [][2]float64) (n int, ok bool) {
+	if e.designedRate != e.sampleRate || e.designedGains != e.gains {
+		e.design()
+	}
+	n, ok = e.streamer.Stream(samples)
+	for i := range e.filters {
+		if !e.usable[i] {
+			continue
+		}
+		// 刚调到 0 dB 的频段先按直通系数继续滤波，等残留的振荡衰减完再跳过
+		if f := &e.filters[i]; e.gains[i] != 0 || !f.settled() {
+			f.process(samples[:n])
+		} else {
+			f.bypass(samples[:n])
+		}
+	}
+	return n, ok
+}
+
+func (e *equalizer) Err() error {
+	return e.streamer.Err()
+}
+
+// setEQ 修改当前均衡器设置，保存到状态文件并应用到正在播放和已排队的曲目
+func setEQ(preset eqPreset, players ...*Player) {
+	lockState()
+	state.EQ = preset
+	_ = saveState()
+	stateMu.Unlock()
+
+	for _, p := range players {
+		p.applyEQ(preset.Gains)
+	}
+}
+
+func (p *Player) applyEQ(gains [eqBandCount]float64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.eq == nil {
+		return
+	}
+	output.Lock()
+	p.eq.gains = gains
+	output.Unlock()
+}
+
+func eqPresetsPath() (string, error) {
+	dir, err := utils.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, eqPresetsFile), nil
+}
+
+// loadCustomEQPresets 读取用户保存的预设，文件不存在时返回空列表
+func loadCustomEQPresets() []eqPreset {
+	path, err := eqPresetsPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var presets []eqPreset
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil
+	}
+	return presets
+}
+
+// saveCustomEQPreset 保存用户预设，同名预设会被覆盖
+func saveCustomEQPreset(preset eqPreset) error {
+	presets := loadCustomEQPresets()
+	replaced := false
+	for i := range presets {
+		if presets[i].Name == preset.Name {
+			presets[i] = preset
+			replaced = true
+		}
+	}
+	if !replaced {
+		presets = append(presets, preset)
+	}
+
+	path, err := eqPresetsPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(presets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// allEQPresets 返回内置预设和用户预设
+func allEQPresets() []eqPreset {
+	return append(append([]eqPreset{}, builtinEQPresets...), loadCustomEQPresets()...)
+}
+
+// findEQPreset 按名字（不区分大小写）查找预设
+func findEQPreset(name string) (eqPreset, bool) {
+	for _, preset := range allEQPresets() {
+		if strings.EqualFold(preset.Name, name) {
+			return preset, true
+		}
+	}
+	return eqPreset{}, false
+}
+
+func eqText() string {
+	lockState()
+	defer stateMu.Unlock()
+	if state.EQ.Gains == ([eqBandCount]float64{}) {
+		return ""
+	}
+	if state.EQ.Name == "" {
+		return "EQ 自定义"
+	}
+	return "EQ " + state.EQ.Name
+}
@@ -0,0 +1,171 @@
+package player
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// eqPanel 是播放界面中的均衡器面板
+// ←/→ 选择频段，↑/↓ 调节增益，p / P 切换预设，0 把当前频段归零，s 保存为自定义预设，e 或 q 关闭
+type eqPanel struct {
+	band    int
+	preset  int // 当前预设在 allEQPresets 中的位置，-1 表示手动调节过
+	current eqPreset
+	presets []eqPreset
+
+	naming  bool   // 正在输入要保存的预设名
+	name    []byte // 已输入的预设名
+	message string // 保存结果等提示
+}
+
+func newEQPanel() *eqPanel {
+	lockState()
+	current := state.EQ
+	stateMu.Unlock()
+
+	p := &eqPanel{current: current, presets: allEQPresets(), preset: -1}
+	for i, preset := range p.presets {
+		if preset == current {
+			p.preset = i
+			break
+		}
+	}
+	return p
+}
+
+func (p *eqPanel) handleKey(k key, players []*Player) bool {
+	if p.naming {
+		p.handleNameKey(k)
+		return true
+	}
+	p.message = ""
+
+	switch k {
+	case 'e', 'E', 'q', 'Q':
+		return false
+	case keyLeft, 'h':
+		p.band = (p.band - 1 + eqBandCount) % eqBandCount
+	case keyRight, 'l':
+		p.band = (p.band + 1) % eqBandCount
+	case keyUp, 'k':
+		p.adjust(1, players)
+	case keyDown, 'j':
+		p.adjust(-1, players)
+	case '0':
+		p.adjust(-p.current.Gains[p.band], players)
+	case 'p':
+		p.selectPreset(p.preset+1, players)
+	case 'P':
+		p.selectPreset(p.preset-1, players)
+	case 's', 'S':
+		p.naming = true
+		p.name = []byte(p.current.Name)
+	}
+	return true
+}
+
+func (p *eqPanel) adjust(delta float64, players []*Player) {
+	gain := p.current.Gains[p.band] + delta
+	if gain > eqMaxGain {
+		gain = eqMaxGain
+	}
+	if gain < -eqMaxGain {
+		gain = -eqMaxGain
+	}
+	p.current.Gains[p.band] = gain
+	p.current.Name = ""
+	p.preset = -1
+	setEQ(p.current, players...)
+}
+
+func (p *eqPanel) selectPreset(i int, players []*Player) {
+	n := len(p.presets)
+	p.preset = (i%n + n) % n
+	p.current = p.presets[p.preset]
+	setEQ(p.current, players...)
+}
+
+// handleNameKey 处理预设名输入，回车保存，名字为空时回车取消，退格删除
+func (p *eqPanel) handleNameKey(k key) {
+	switch {
+	case k == keyEnter || k == '\n':
+		p.naming = false
+		name := strings.TrimSpace(string(p.name))
+		if name == "" {
+			return
+		}
+		p.current.Name = name
+		if err := saveCustomEQPreset(p.current); err != nil {
+			p.message = "保存失败: " + err.Error()
+			return
+		}
+		lockState()
+		state.EQ = p.current
+		_ = saveState()
+		stateMu.Unlock()
+		p.presets = allEQPresets()
+		for i, preset := range p.presets {
+			if preset == p.current {
+				p.preset = i
+			}
+		}
+		p.message = "已保存预设 " + name
+	case k == keyBackspace || k == 8:
+		if len(p.name) > 0 {
+			_, size := utf8.DecodeLastRune(p.name)
+			p.name = p.name[:len(p.name)-size]
+		}
+	case k >= 0x20 && k < 0x100:
+		p.name = append(p.name, byte(k))
+	}
+}
+
+func (p *eqPanel) lines() []string {
+	name := p.current.Name
+	if name == "" {
+		name = "自定义"
+	}
+	lines := []string{fmt.Sprintf("  \x1b[1m均衡器\x1b[0m  预设: %s", name)}
+	for i, gain := range p.current.Gains {
+		marker := "  "
+		if i == p.band {
+			marker = "\x1b[34m➣ "
+		}
+		lines = append(lines, fmt.Sprintf("  %s%6s %s %+5.1fdB\x1b[0m", marker, formatFrequency(eqFrequencies[i]), gainBar(gain), gain))
+	}
+	switch {
+	case p.naming:
+		lines = append(lines, "  保存为: "+string(p.name)+"_  （回车保存，清空后回车取消）")
+	case p.message != "":
+		lines = append(lines, "  "+p.message)
+	default:
+		lines = append(lines, "  ←/→ 选择频段  ↑/↓ 调节  0 归零  p/P 切换预设  s 保存预设  e 关闭")
+	}
+	return lines
+}
+
+// gainBar 把增益画成以 0 dB 为中心的横条，每格 1 dB
+func gainBar(gain float64) string {
+	var b strings.Builder
+	for i := -int(eqMaxGain); i <= int(eqMaxGain); i++ {
+		switch {
+		case i == 0:
+			b.WriteString("│")
+		case i < 0 && float64(i) >= gain:
+			b.WriteString("█")
+		case i > 0 && float64(i) <= gain:
+			b.WriteString("█")
+		default:
+			b.WriteString("·")
+		}
+	}
+	return b.String()
+}
+
+func formatFrequency(f float64) string {
+	if f >= 1000 {
+		return fmt.Sprintf("%gkHz", f/1000)
+	}
+	return fmt.Sprintf("%gHz", f)
+}
@@ -0,0 +1,75 @@
+package player
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/beep"
+)
+
+// maxStep 返回相邻两个输出采样之间的最大差值，滤波器历史被清空时这里会出现一个大跳变
+func maxStep(samples [][2]float64) float64 {
+	step := 0.0
+	for i := 1; i < len(samples); i++ {
+		step = max(step, math.Abs(samples[i][0]-samples[i-1][0]))
+	}
+	return step
+}
+
+func TestEqualizerGainChange(t *testing.T) {
+	const sr = beep.SampleRate(44100)
+	n := 0
+	sine := beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		for i := range samples {
+			v := 0.25 * math.Sin(2*math.Pi*1000*float64(n)/float64(sr))
+			samples[i] = [2]float64{v, v}
+			n++
+		}
+		return len(samples), true
+	})
+
+	// 1 kHz 频段依次调节，每次调节前后都播放足够长，让输出稳定下来
+	steps := []float64{6, 7, 0, 0, 6, -12, 0}
+	e := &equalizer{streamer: sine, sampleRate: sr}
+	const chunk, chunks = 512, 40
+	var last [2]float64
+	for i, gain := range steps {
+		e.gains[5] = gain
+		// 带上调节前的最后一个采样，跳变就发生在它和下一个采样之间
+		out := [][2]float64{last}
+		for range chunks {
+			buf := make([][2]float64, chunk)
+			e.Stream(buf)
+			out = append(out, buf...)
+		}
+		last = out[len(out)-1]
+		if i == 0 {
+			continue
+		}
+		// 稳定后的最大步长取决于输出幅度；调节的瞬间不应该超过调节前后两者中较大的那个太多
+		prev, settled := steps[i-1], out[len(out)/2:]
+		limit := maxStep(settled) * max(1, math.Pow(10, (prev-gain)/20)) * 1.1
+		if step := maxStep(out[:chunk+1]); step > limit {
+			t.Errorf("%+.0f dB -> %+.0f dB: output jumps by %.4f, want at most %.4f", prev, gain, step, limit)
+		}
+	}
+}
+
+func TestEqualizerFlatIsTransparent(t *testing.T) {
+	const sr = beep.SampleRate(44100)
+	input := make([][2]float64, 4096)
+	for i := range input {
+		input[i] = [2]float64{math.Sin(float64(i) / 7), math.Cos(float64(i) / 3)}
+	}
+	buf := make([][2]float64, len(input))
+	copy(buf, input)
+	e := &equalizer{streamer: beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		return len(samples), true
+	}), sampleRate: sr}
+	e.Stream(buf)
+	for i := range buf {
+		if buf[i] != input[i] {
+			t.Fatalf("flat equalizer changed sample %d: %v -> %v", i, input[i], buf[i])
+		}
+	}
+}
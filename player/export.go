@@ -0,0 +1,240 @@
+package player
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"music-cli/codec"
+	"music-cli/utils"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+)
+
+const exportUsage = `用法：music-cli export [选项] -o 输出文件 输入...
+
+输入可以是音频文件、CUE 文件、播放列表或目录（递归），多个输入按顺序拼接成一个文件。
+输出格式由扩展名决定：.wav 或 .flac。
+音量、ReplayGain、均衡器和声道设置默认使用播放器当前保存的设置，可以用选项覆盖，覆盖不会保存。
+
+选项：
+`
+
+// Export 是 music-cli export 子命令：不打开声卡，把曲目经过和播放时相同的效果链后写入文件
+func Export(args []string) error {
+	lockState()
+	savedVolume, savedEQ, savedChannels, savedSkip := state.Volume, state.EQ.Name, state.Channels, state.SkipSilence
+	stateMu.Unlock()
+
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), exportUsage)
+		fs.PrintDefaults()
+	}
+	outPath := fs.String("o", "", "输出文件（.wav 或 .flac）")
+	speed := fs.Float64("speed", 1, fmt.Sprintf("播放速度（%.1f - %.1f）", minSpeed, maxSpeed))
+	volume := fs.Float64("volume", savedVolume, fmt.Sprintf("音量（dB，%.0f - %+.0f）", minVolume, maxVolume))
+	rgMode := fs.String("replaygain", currentReplayGainMode(), "ReplayGain 模式：off / track / album / auto")
+	eqName := fs.String("eq", savedEQ, "均衡器预设名称")
+	balance := fs.Float64("balance", savedChannels.Balance, "左右平衡（-1 左 - 1 右）")
+	mono := fs.Bool("mono", savedChannels.Mono, "混音为单声道")
+	swap := fs.Bool("swap", savedChannels.Swap, "左右声道互换")
+	skipSilence := fs.Bool("skip-silence", savedSkip, "去掉开头、结尾和中间较长的静音")
+	rate := fs.Int("rate", int(outputSampleRate), "输出采样率")
+	bitDepth := fs.Int("bits", 16, "位深：16 或 24")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if *outPath == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("需要指定输出文件和至少一个输入")
+	}
+	encode, err := exportEncoder(*outPath)
+	if err != nil {
+		return err
+	}
+	if *speed < minSpeed || *speed > maxSpeed {
+		return fmt.Errorf("速度超出范围：%.2f", *speed)
+	}
+	if *volume < minVolume || *volume > maxVolume {
+		return fmt.Errorf("音量超出范围：%.1fdB", *volume)
+	}
+	if !isReplayGainMode(*rgMode) {
+		return fmt.Errorf("未知的 ReplayGain 模式：%s", *rgMode)
+	}
+	if *balance < -maxBalance || *balance > maxBalance {
+		return fmt.Errorf("平衡超出范围：%.2f", *balance)
+	}
+	if *bitDepth != 16 && *bitDepth != 24 {
+		return fmt.Errorf("不支持的位深：%d", *bitDepth)
+	}
+	if *rate <= 0 {
+		return fmt.Errorf("无效的采样率：%d", *rate)
+	}
+
+	// 效果链在创建时读取这些全局设置，这里只改内存中的值，不写回状态文件
+	lockState()
+	state.Volume = *volume
+	state.Muted = false
+	state.ReplayGain = *rgMode
+	state.SkipSilence = *skipSilence
+	state.Channels = channelSettings{Balance: *balance, Mono: *mono, Swap: *swap}
+	if *eqName != savedEQ {
+		preset, ok := findEQPreset(*eqName)
+		if !ok {
+			stateMu.Unlock()
+			return fmt.Errorf("找不到均衡器预设：%s", *eqName)
+		}
+		state.EQ = preset
+	}
+	stateMu.Unlock()
+
+	// 导出时不需要声卡，效果链加锁时用一个不启动的输出后端
+	SetOutput(NewNullOutput(false))
+	output.Lock()
+	playbackSpeed = *speed
+	output.Unlock()
+
+	players, err := exportPlayers(fs.Args())
+	if err != nil {
+		return err
+	}
+	if len(players) == 0 {
+		return errors.New("没有可以导出的曲目")
+	}
+
+	f, err := os.Create(*outPath)
+	if err != nil {
+		return err
+	}
+	mix := &exportStreamer{players: players, rate: beep.SampleRate(*rate)}
+	format := beep.Format{SampleRate: beep.SampleRate(*rate), NumChannels: 2, Precision: *bitDepth / 8}
+	err = encode(f, mix, format)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已导出 %d 首曲目到 %s（%s）\n", mix.exported, *outPath, format.SampleRate.D(mix.written).Round(1e9))
+	return nil
+}
+
+// exportEncoder 根据输出文件的扩展名选择编码器
+func exportEncoder(path string) (func(f *os.File, s beep.Streamer, format beep.Format) error, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		return func(f *os.File, s beep.Streamer, format beep.Format) error {
+			return wav.Encode(f, s, format)
+		}, nil
+	case ".flac":
+		return func(f *os.File, s beep.Streamer, format beep.Format) error {
+			return codec.EncodeFLAC(f, s, format)
+		}, nil
+	}
+	return nil, fmt.Errorf("不支持的输出格式：%s（只支持 .wav 和 .flac）", filepath.Ext(path))
+}
+
+// exportPlayers 把命令行上的输入展开成 Player 列表
+func exportPlayers(inputs []string) ([]*Player, error) {
+	var players []*Player
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			// CUE 虚拟音轨的路径不是真实文件
+			if _, _, ok := utils.SplitCueTrackPath(input); ok {
+				players = append(players, getPlayerList([]string{input})...)
+				continue
+			}
+			return nil, err
+		}
+		switch {
+		case info.IsDir():
+			paths, err := utils.WalkDir(input)
+			if err != nil {
+				return nil, err
+			}
+			players = append(players, getPlayerList(paths)...)
+		case utils.IsPlaylist(input):
+			list, skipped, err := loadPlaylist(input)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range skipped {
+				fmt.Fprintln(os.Stderr, "跳过：", entry)
+			}
+			players = append(players, list...)
+		default:
+			paths, _, err := utils.ListDir(input)
+			if err != nil {
+				return nil, err
+			}
+			if len(paths) == 0 {
+				return nil, fmt.Errorf("不支持的文件：%s", input)
+			}
+			players = append(players, getPlayerList(paths)...)
+		}
+	}
+	for i, p := range players {
+		p.id = i + 1
+	}
+	return players, nil
+}
+
+// exportStreamer 依次解码每首曲目，经过效果链并重采样到输出采样率后拼接在一起
+// 同一时间只打开一首曲目
+type exportStreamer struct {
+	players  []*Player
+	rate     beep.SampleRate
+	index    int
+	current  *Player
+	stream   beep.Streamer
+	exported int
+	written  int
+}
+
+func (e *exportStreamer) Stream(samples [][2]float64) (n int, ok bool) {
+	for n < len(samples) {
+		if e.stream == nil && !e.open() {
+			break
+		}
+		sn, sok := e.stream.Stream(samples[n:])
+		n += sn
+		if !sok {
+			e.current.Close()
+			e.current, e.stream = nil, nil
+		}
+	}
+	e.written += n
+	return n, n > 0
+}
+
+// open 打开下一首能解码的曲目，没有更多曲目时返回 false
+func (e *exportStreamer) open() bool {
+	for e.index < len(e.players) {
+		p := e.players[e.index]
+		e.index++
+		if err := p.Init(); err != nil {
+			fmt.Fprintf(os.Stderr, "[%d/%d] 跳过 %s：%v\n", e.index, len(e.players), utils.TrackName(p.path), err)
+			p.Close()
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s\n", e.index, len(e.players), utils.TrackName(p.path))
+		e.current = p
+		e.stream = beep.Resample(resampleQuality, p.format.SampleRate, e.rate, p.tap)
+		e.exported++
+		return true
+	}
+	return false
+}
+
+func (e *exportStreamer) Err() error {
+	return nil
+}
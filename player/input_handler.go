@@ -2,12 +2,16 @@ package player
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"music-cli/utils"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"golang.org/x/term"
@@ -18,16 +22,36 @@ const (
 	toMenuSignal = 1
 	toHomeSignal = 2
 )
+
+const (
+	shortSeekStep = 5 * time.Second
+	longSeekStep  = 30 * time.Second
+)
 const welcomeMessage = `欢迎使用music-cli音乐播放器
 
 基本操作
 播放 / 暂停：空格 (Space)
 上一首： -
 下一首： +
+快退 / 快进 5 秒：← / → 或 h / l
+快退 / 快进 30 秒：H / L
+音量加 / 减：↑ / ↓ 或 k / j
+静音：m
+淡入淡出时长切换（关 / 2 / 5 / 10 秒）：x
+ReplayGain 模式切换（关 / 单曲 / 专辑 / 自动）：g
+减速 / 加速（0.5x - 2x）：[ / ]，恢复原速：=
+循环模式切换（列表循环 / 单曲循环 / 顺序播放）：r
+跳过静音（开头、结尾和中间超过 2 秒的静音）：s
+睡眠定时（定时 / 本曲结束后 / 播放 N 首后，到时淡出）：t
+保存当前播放列表为 m3u8（保存在当前目录）：w
+A-B 循环：a 设置 A 点，b 设置 B 点并开始循环，c 取消
+均衡器：e（←/→ 选择频段，↑/↓ 调节，p 切换预设，s 保存预设）
+音频设置（左右平衡 / 单声道 / 左右互换）：o
+可视化切换（关 / 频谱 / 电平表 / 频谱+电平表）：v
 退出播放返回目录：q / Q
 
 菜单与浏览
-输入编号：播放对应音乐或进入目录
+输入编号：播放对应音乐、播放列表（m3u / m3u8 / pls）或进入目录
 下一页 / 上一页：输入 + / -
 跳转到指定页：pN（例如 p2）
 切换盘符：c:（c是一个字母）
@@ -36,7 +60,11 @@ const welcomeMessage = `欢迎使用music-cli音乐播放器
 当前目录全部播放（递归）：a
 递归随机播放：ar
 随机播放当前页：0r
-随机播放当前页单首：r`
+随机播放当前页单首：r
+按速度（BPM）排序播放：0b（当前页）/ ab（递归）
+按调性排序播放（Camelot 调性轮顺序）：0k / ak
+按条件筛选：在 0b / ab / 0k / ak / 0r / ar 后面加速度范围或 Camelot 调性，例如 ab 120-130、ar 125-135 8A
+（没有分析过的曲目会先分析，也可以用 music-cli analyze 提前分析整个目录）`
 
 type pageChange struct {
 	signal int
@@ -52,12 +80,68 @@ func handlePlayInput(root string, start int, page int, plist []*Player) {
 	if err != nil {
 		return
 	}
+	// 播放期间由这里处理中断信号，保存进度后再退出
+	playing.Store(true)
+	defer playing.Store(false)
 
 	currentIndex := start
 	currentPlayer := plist[currentIndex]
+	var nextPlayer *Player
+	var doneCh chan struct{}
+
+	// preload 提前解码下一首并排进播放队列，当前曲目一结束就能无缝接上
+	// 同一张专辑的曲目之间不做淡入淡出
+	preload := func() {
+		nextPlayer = nil
+		index := nextIndex(currentIndex, len(plist))
+		if index < 0 {
+			return
+		}
+		next := plist[index]
+		if next == currentPlayer {
+			return
+		}
+		if err := next.Init(); err != nil {
+			return
+		}
+		if err := next.enqueue(!sameAlbum(currentPlayer, next)); err != nil {
+			return
+		}
+		nextPlayer = next
+	}
+
+	// startAt 开始播放 index 对应的曲目，如果它已经预加载就直接接管
+	startAt := func(index int) {
+		currentIndex = index
+		currentPlayer = plist[currentIndex]
+		if currentPlayer != nextPlayer {
+			currentPlayer.Init()
+		}
+		go currentPlayer.Play()
+		doneCh = currentPlayer.done
+		preload()
+	}
+
+	// jumpTo 手动切歌，丢弃当前曲目和不再需要的预加载曲目
+	jumpTo := func(index int) {
+		rememberPosition(currentPlayer)
+		currentPlayer.Close()
+		if nextPlayer != nil && nextPlayer != plist[index] {
+			nextPlayer.Close()
+			nextPlayer = nil
+		}
+		startAt(index)
+	}
+
+	// replan 循环模式改变后，重新决定预加载哪一首
+	replan := func() {
+		if nextPlayer != nil {
+			nextPlayer.Close()
+		}
+		preload()
+	}
 
-	currentPlayer.Init()
-	go currentPlayer.Play()
+	startAt(currentIndex)
 
 	bytesCh := make(chan byte, 16)
 
@@ -80,49 +164,184 @@ func handlePlayInput(root string, start int, page int, plist []*Player) {
 		}
 	}()
 
-	doneCh := currentPlayer.done
+	// saveProgress 记录播放列表和位置，除了列表正常播完，离开播放界面时都要调用
+	saveProgress := func() {
+		rememberPosition(currentPlayer)
+		saveSession(root, page, plist, currentIndex, currentPlayer.getCurrentTime())
+	}
+
+	// saveFinished 当前曲目刚播完时代替 saveProgress，下次从下一首的开头继续，列表已经播完就不用继续
+	saveFinished := func() {
+		index := nextIndex(currentIndex, len(plist))
+		if index < 0 {
+			clearSession()
+			return
+		}
+		saveSession(root, page, plist, index, 0)
+	}
+
+	quit := func() {
+		closePanel()
+		currentPlayer.Close()
+		if nextPlayer != nil {
+			nextPlayer.Close()
+		}
+		close(readerQuit)
+		pageChannel <- pageChange{signal: toMenuSignal, root: root, page: page}
+	}
+
+	// exitProgram 恢复终端后退出程序，调用前先保存进度
+	exitProgram := func() {
+		currentPlayer.Close()
+		if nextPlayer != nil {
+			nextPlayer.Close()
+		}
+		closePanel()
+		term.Restore(int(os.Stdin.Fd()), oldState)
+		fmt.Print("\x1b[?25h\033[2J\033[H")
+		exit(0)
+	}
+
+	// sleepQuit 睡眠定时到时，淡出已经完成，用 save 保存进度后按设置返回目录或退出程序
+	sleepQuit := func(save func()) {
+		exitAfter := sleepExitsProgram()
+		cancelSleepTimer()
+		save()
+		if exitAfter {
+			exitProgram()
+		}
+		quit()
+	}
+
+	sleepTicker := time.NewTicker(sleepCheckPeriod)
+	defer sleepTicker.Stop()
+
+	var keys keyDecoder
 
 	for {
 		select {
 		case b := <-bytesCh:
-			switch b {
+			k := keys.feed(b)
+			if k == keyNone {
+				continue
+			}
+			// 原始模式下 Ctrl-C 不会产生 SIGINT，只是一个普通字节
+			if k == keyCtrlC {
+				saveProgress()
+				exitProgram()
+			}
+			// 设置面板打开时由面板处理按键
+			if handlePanelKey(k, currentPlayer, nextPlayer) {
+				continue
+			}
+			switch k {
 			case ' ':
 				currentPlayer.TogglePause()
+			case keyRight, 'l':
+				currentPlayer.seek(shortSeekStep)
+			case keyLeft, 'h':
+				currentPlayer.seek(-shortSeekStep)
+			case 'L':
+				currentPlayer.seek(longSeekStep)
+			case 'H':
+				currentPlayer.seek(-longSeekStep)
+			case keyUp, 'k':
+				changeVolume(volumeStep, currentPlayer, nextPlayer)
+			case keyDown, 'j':
+				changeVolume(-volumeStep, currentPlayer, nextPlayer)
+			case 'm', 'M':
+				toggleMute(currentPlayer, nextPlayer)
+			case 'x', 'X':
+				cycleCrossfade()
+			case 'g', 'G':
+				cycleReplayGain(currentPlayer, nextPlayer)
+			case '[':
+				changeSpeed(-speedStep, currentPlayer, nextPlayer)
+			case ']':
+				changeSpeed(speedStep, currentPlayer, nextPlayer)
+			case '=':
+				changeSpeed(0, currentPlayer, nextPlayer)
+			case 'e', 'E':
+				openPanel(newEQPanel())
+			case 'a', 'A':
+				currentPlayer.setLoopA()
+			case 'b', 'B':
+				currentPlayer.setLoopB()
+			case 'c', 'C':
+				currentPlayer.clearLoop()
 			case '+':
-				currentPlayer.Close()
-				currentIndex = (currentIndex + 1) % len(plist)
-				currentPlayer = plist[currentIndex]
-				currentPlayer.Init()
-				go currentPlayer.Play()
-				doneCh = currentPlayer.done
+				jumpTo((currentIndex + 1) % len(plist))
 			case '-':
 				fmt.Println("Previous track")
-				currentPlayer.Close()
-				currentIndex = (currentIndex - 1 + len(plist)) % len(plist)
-				currentPlayer = plist[currentIndex]
-				currentPlayer.Init()
-				go currentPlayer.Play()
-				doneCh = currentPlayer.done
+				jumpTo((currentIndex - 1 + len(plist)) % len(plist))
+			case 'w', 'W':
+				showNotice("正在保存播放列表...")
+				go func(plist []*Player) {
+					path, err := saveQueue(playlistDir(root), plist)
+					if err != nil {
+						showNotice("保存失败：" + err.Error())
+						return
+					}
+					showNotice("已保存到 " + filepath.Base(path))
+				}(plist)
+			case 't', 'T':
+				openPanel(newSleepPanel())
+			case 'o', 'O':
+				openPanel(newAudioPanel())
+			case 's', 'S':
+				toggleSkipSilence(currentPlayer, nextPlayer)
+			case 'v', 'V':
+				cycleVizMode()
+				showNotice("可视化：" + vizText())
+			case 'r', 'R':
+				cycleRepeatMode()
+				replan()
 			case 'q', 'Q':
-				currentPlayer.Close()
-				close(readerQuit)
-				pageChannel <- pageChange{signal: toMenuSignal, root: root, page: page}
+				saveProgress()
+				quit()
+				return
+			}
+		case <-interrupted:
+			saveProgress()
+			exitProgram()
+		case <-sleepTicker.C:
+			if checkSleepTimer(currentPlayer, currentPlayer, nextPlayer) {
+				sleepQuit(saveProgress)
 				return
 			}
 		case <-doneCh:
-			currentIndex = (currentIndex + 1) % len(plist)
-			currentPlayer = plist[currentIndex]
-			currentPlayer.Init()
-			go currentPlayer.Play()
-			doneCh = currentPlayer.done
+			// 长文件播完后下次从头开始
+			forgetPosition(currentPlayer.path)
+			if sleepTrackFinished() {
+				sleepQuit(saveFinished)
+				return
+			}
+			index := nextIndex(currentIndex, len(plist))
+			if index < 0 {
+				// 顺序播放模式下列表播完，返回目录，没有需要继续播放的内容
+				clearSession()
+				quit()
+				return
+			}
+			// 预加载的下一首此时已经在播放，这里只需要切换界面
+			startAt(index)
 		}
 	}
 }
 
+// playlistDir 返回保存播放列表的目录，root 是文件（例如直接打开的 CUE）时用它所在的目录
+func playlistDir(root string) string {
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		return filepath.Dir(root)
+	}
+	return root
+}
+
 func handleMenu(root string, page int) error {
 	fmt.Print("\033[2J\033[H")
 	files, dir, err := utils.ListDir(root)
-	if len(files) == 0 && len(dir) == 0 {
+	playlists, _ := utils.ListPlaylists(root)
+	if len(files) == 0 && len(playlists) == 0 && len(dir) == 0 {
 		fmt.Print("当前目录为空")
 		time.Sleep(1 * time.Second)
 		if root == "/" || root == "\\" || len(filepath.Dir(root)) >= len(root) {
@@ -144,7 +363,7 @@ func handleMenu(root string, page int) error {
 	scanner.Scan()
 	input = strings.Trim(scanner.Text(), " \t\n\r'\"")
 	if input == "" {
-		os.Exit(0)
+		exit(0)
 	}
 	needReturn, err := handleMenuInput(root, page, input, files)
 	if needReturn || err != nil {
@@ -155,7 +374,7 @@ func handleMenu(root string, page int) error {
 		scanner.Scan()
 		input = strings.Trim(scanner.Text(), " \t\n\r'\"")
 		if input == "" {
-			os.Exit(0)
+			exit(0)
 		}
 		needReturn, err = handleMenuInput(root, page, input, files)
 		if needReturn || err != nil {
@@ -167,24 +386,63 @@ func handleMenu(root string, page int) error {
 		player := NewPlayer(files[index-1], 1)
 		handlePlayInput(root, 0, page, []*Player{player})
 		return nil
-	} else if index <= len(files)+len(dir) {
-		pageChannel <- pageChange{signal: toMenuSignal, root: dir[index-len(files)-1], page: 1}
+	} else if index <= len(files)+len(playlists) {
+		playPlaylist(root, page, playlists[index-len(files)-1], scanner)
+		return nil
+	} else if index <= len(files)+len(playlists)+len(dir) {
+		pageChannel <- pageChange{signal: toMenuSignal, root: dir[index-len(files)-len(playlists)-1], page: 1}
 		return nil
 	}
 	pageChannel <- pageChange{signal: toMenuSignal, root: root}
 	return nil
 }
 
+// playPlaylist 播放播放列表，有条目缺失时先列出来，按回车后继续播放其余曲目
+func playPlaylist(root string, page int, path string, scanner *bufio.Scanner) {
+	players, skipped, err := loadPlaylist(path)
+	if err != nil {
+		fmt.Println("错误:", err)
+		time.Sleep(1 * time.Second)
+		pageChannel <- pageChange{signal: toMenuSignal, root: root, page: page}
+		return
+	}
+	if len(skipped) > 0 {
+		fmt.Printf("播放列表中有 %d 个条目无法播放，已跳过：\n", len(skipped))
+		for _, entry := range skipped {
+			fmt.Println(" ", entry)
+		}
+		if len(players) > 0 {
+			fmt.Print("按回车继续播放其余曲目：")
+		} else {
+			fmt.Print("没有可以播放的曲目，按回车返回目录：")
+		}
+		scanner.Scan()
+	}
+	if len(players) == 0 {
+		pageChannel <- pageChange{signal: toMenuSignal, root: root, page: page}
+		return
+	}
+	handlePlayInput(root, 0, page, players)
+}
+
 func handleHomeInput() {
 	fmt.Print("\033[2J\033[H")
 	fmt.Println(welcomeMessage)
 	fmt.Println()
+	last, hasSession := loadSession()
+	if hasSession {
+		fmt.Println("输入 c 继续上次播放：" + last.describe())
+	}
 	fmt.Print("请输入音乐路径(回车或q键直接退出)：")
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Scan()
 	path := strings.Trim(scanner.Text(), " \t\n\r'\"")
 	if path == "q" || path == "Q" || path == "" {
-		os.Exit(0)
+		exit(0)
+	}
+	if hasSession && (path == "c" || path == "C") {
+		handlePlayInput(last.Root, last.Index, last.Page, last.players())
+		return
 	}
 	info, err := os.Lstat(path)
 	for err != nil {
@@ -192,10 +450,21 @@ func handleHomeInput() {
 		scanner.Scan()
 		path = strings.Trim(scanner.Text(), " \t\n\r'\"")
 		if path == "q" || path == "Q" || path == "" {
-			os.Exit(0)
+			exit(0)
 		}
 		info, err = os.Lstat(path)
 	}
+	if utils.IsCue(path) {
+		// CUE 文件展开成其中的全部音轨
+		if files, _, err := utils.ListDir(path); err == nil && len(files) > 0 {
+			handlePlayInput(filepath.Dir(path), 0, 1, getPlayerList(files))
+			return
+		}
+	}
+	if utils.IsPlaylist(path) {
+		playPlaylist(filepath.Dir(path), 1, path, scanner)
+		return
+	}
 	if !info.IsDir() {
 		player := NewPlayer(path, 1)
 		handlePlayInput(filepath.Dir(path), 0, 1, []*Player{player})
@@ -204,21 +473,50 @@ func handleHomeInput() {
 	pageChannel <- pageChange{signal: toMenuSignal, root: path, page: 1}
 }
 
+var (
+	// interrupted 在收到 SIGINT 或 SIGTERM 后关闭
+	interrupted <-chan struct{}
+	// playing 表示播放界面正在运行，这时由播放界面处理中断
+	playing atomic.Bool
+)
+
 func PageController() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	interrupted = ctx.Done()
+
 	go handleHomeInput()
+	signals := interrupted
 	for {
-		switch pc := <-pageChannel; pc.signal {
-		case toMenuSignal:
-			go handleMenu(pc.root, pc.page)
-		case toHomeSignal:
-			go handleHomeInput()
-		case exitSignal:
-			return
+		select {
+		case pc := <-pageChannel:
+			switch pc.signal {
+			case toMenuSignal:
+				go handleMenu(pc.root, pc.page)
+			case toHomeSignal:
+				go handleHomeInput()
+			case exitSignal:
+				_ = output.Close()
+				return
+			}
+		case <-signals:
+			// 播放界面会保存进度后退出；它恰好在退出播放时（进度已经保存）就等它返回
+			signals = nil
+			go func() {
+				for playing.Load() {
+					time.Sleep(50 * time.Millisecond)
+				}
+				fmt.Println()
+				exit(0)
+			}()
 		}
 	}
 }
 
 func handleMenuInput(root string, page int, input string, files []string) (bool, error) {
+	if needReturn, err := handleOrderedPlay(root, page, input, files); needReturn || err != nil {
+		return needReturn, err
+	}
 	switch input {
 	case "q", "Q":
 		pageChannel <- pageChange{signal: toHomeSignal, root: root}
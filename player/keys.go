@@ -0,0 +1,51 @@
+package player
+
+// key 是解码后的按键，普通按键就是对应的字节，方向键用负数表示
+type key int
+
+const (
+	keyNone key = -iota
+	keyUp
+	keyDown
+	keyRight
+	keyLeft
+)
+
+const (
+	keyCtrlC     key = 0x03
+	keyEnter     key = '\r'
+	keyBackspace key = 127
+)
+
+// keyDecoder 把终端输入的字节解码成按键
+// 方向键是 ESC [ A/B/C/D 这样的三字节序列，escState 记录读到了第几个字节
+type keyDecoder struct {
+	escState int
+}
+
+// feed 输入一个字节，序列还没读完时返回 keyNone
+func (d *keyDecoder) feed(b byte) key {
+	switch {
+	case d.escState == 0 && b == 0x1b:
+		d.escState = 1
+		return keyNone
+	case d.escState == 1 && b == '[':
+		d.escState = 2
+		return keyNone
+	case d.escState == 2:
+		d.escState = 0
+		switch b {
+		case 'A':
+			return keyUp
+		case 'B':
+			return keyDown
+		case 'C':
+			return keyRight
+		case 'D':
+			return keyLeft
+		}
+		return keyNone
+	}
+	d.escState = 0
+	return key(b)
+}
@@ -0,0 +1,356 @@
+package player
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"music-cli/codec"
+	"music-cli/utils"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const loudnessUsage = `用法：music-cli loudness [选项] [目录或文件...]
+
+按 EBU R128 测量每个文件的综合响度、真峰值和响度范围（LRA），同一目录下的文件作为一张专辑计算专辑值。
+不指定输入时扫描当前目录（包括子目录）。默认只输出报告，加 -write 才会把 ReplayGain 标签写回文件
+（FLAC 写入 Vorbis 注释，MP3 写入 ID3v2 的 TXXX 帧，其他格式跳过）。
+
+选项：
+`
+
+// ReplayGain 2.0 以 -18 LUFS 为参考响度
+const replayGainReference = -18.0
+
+// loudnessResult 是一个文件的测量结果
+type loudnessResult struct {
+	path     string
+	loudness codec.Loudness
+	duration time.Duration
+	err      error
+}
+
+// Loudness 是 music-cli loudness 子命令
+func Loudness(args []string) error {
+	fs := flag.NewFlagSet("loudness", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), loudnessUsage)
+		fs.PrintDefaults()
+	}
+	write := fs.Bool("write", false, "把 ReplayGain 标签写回文件")
+	workers := fs.Int("j", runtime.NumCPU(), "同时分析的文件数")
+	reportPath := fs.String("o", "", "报告文件，扩展名为 .csv 时输出 CSV，默认输出到终端")
+	reference := fs.Float64("reference", replayGainReference, "计算增益时的参考响度（LUFS）")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if *workers < 1 {
+		return fmt.Errorf("无效的并发数：%d", *workers)
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"."}
+	}
+	paths, err := loudnessPaths(inputs)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errors.New("没有找到可以分析的音频文件")
+	}
+
+	results := measureAll(paths, *workers)
+	albums := albumLoudness(results)
+
+	out := io.Writer(os.Stdout)
+	if *reportPath != "" {
+		f, err := os.Create(*reportPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if strings.EqualFold(filepath.Ext(*reportPath), ".csv") {
+		err = writeLoudnessCSV(out, results, albums, *reference)
+	} else {
+		err = writeLoudnessReport(out, results, albums, *reference)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *write {
+		written, skipped, failed := writeReplayGain(results, albums, *reference)
+		fmt.Fprintf(os.Stderr, "已写入 %d 个文件的 ReplayGain 标签，%d 个格式不支持写入已跳过，%d 个失败\n", written, skipped, failed)
+	}
+	return nil
+}
+
+// writeReplayGain 把测量结果写成 ReplayGain 标签，只写 FLAC 和 MP3，其他格式跳过，分析失败或静音的文件不写
+func writeReplayGain(results []loudnessResult, albums map[string]codec.Loudness, reference float64) (written, skipped, failed int) {
+	for _, r := range results {
+		if r.err != nil || math.IsInf(r.loudness.Integrated, -1) {
+			continue
+		}
+		if format, ok := codec.Detect(r.path); !ok || (format.Name != "flac" && format.Name != "mp3") {
+			skipped++
+			continue
+		}
+		if err := codec.WriteTags(r.path, replayGainFields(r.loudness, albums[filepath.Dir(r.path)], reference)); err != nil {
+			fmt.Fprintf(os.Stderr, "写入标签失败 %s：%v\n", r.path, err)
+			failed++
+			continue
+		}
+		written++
+	}
+	return written, skipped, failed
+}
+
+// loudnessPaths 展开输入的目录，CUE 音轨换成它引用的整轨文件（标签写在整轨文件上），每个文件只出现一次
+func loudnessPaths(inputs []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if key := filepath.Clean(path); !seen[key] {
+			seen[key] = true
+			paths = append(paths, path)
+		}
+	}
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if !codec.IsSupported(input) {
+				return nil, fmt.Errorf("不支持的文件：%s", input)
+			}
+			add(input)
+			continue
+		}
+		files, err := utils.WalkDir(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range files {
+			if _, _, ok := utils.SplitCueTrackPath(path); ok {
+				track, err := utils.LoadCueTrack(path)
+				if err != nil {
+					return nil, err
+				}
+				add(track.File)
+				continue
+			}
+			add(path)
+		}
+	}
+	return paths, nil
+}
+
+// measureAll 用固定数量的 worker 并行分析，结果按输入顺序返回
+func measureAll(paths []string, workers int) []loudnessResult {
+	results := make([]loudnessResult, len(paths))
+	forEachPath(paths, workers, func(i int) error {
+		results[i] = measureLoudness(paths[i])
+		return results[i].err
+	}, printProgress(len(paths)))
+	return results
+}
+
+// forEachPath 用 workers 个 goroutine 对每个路径调用 fn，每完成一个调用一次 progress（不会同时调用）
+func forEachPath(paths []string, workers int, fn func(i int) error, progress func(path string, err error)) {
+	jobs := make(chan int)
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for w := 0; w < min(workers, len(paths)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				err := fn(i)
+				mu.Lock()
+				progress(paths[i], err)
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// printProgress 返回在标准错误上逐行输出进度的回调
+func printProgress(total int) func(string, error) {
+	finished := 0
+	return func(path string, err error) {
+		finished++
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[%d/%d] 跳过 %s：%v\n", finished, total, path, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s\n", finished, total, path)
+		}
+	}
+}
+
+func measureLoudness(path string) loudnessResult {
+	result := loudnessResult{path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	streamer, format, err := codec.Decode(path, f)
+	if err != nil {
+		f.Close()
+		result.err = err
+		return result
+	}
+	defer streamer.Close()
+
+	meter := codec.NewLoudnessMeter(format.SampleRate, format.NumChannels)
+	buf := make([][2]float64, 8192)
+	total := 0
+	for {
+		n, ok := streamer.Stream(buf)
+		meter.Write(buf[:n])
+		total += n
+		if !ok {
+			break
+		}
+	}
+	if err := streamer.Err(); err != nil {
+		result.err = err
+		return result
+	}
+	result.loudness = meter.Result()
+	result.duration = format.SampleRate.D(total)
+	return result
+}
+
+// albumLoudness 把同一目录下的文件当作一张专辑
+func albumLoudness(results []loudnessResult) map[string]codec.Loudness {
+	tracks := make(map[string][]codec.Loudness)
+	for _, r := range results {
+		if r.err == nil {
+			dir := filepath.Dir(r.path)
+			tracks[dir] = append(tracks[dir], r.loudness)
+		}
+	}
+	albums := make(map[string]codec.Loudness, len(tracks))
+	for dir, list := range tracks {
+		albums[dir] = codec.AlbumLoudness(list)
+	}
+	return albums
+}
+
+// replayGainFields 生成要写入的 ReplayGain 标签，峰值使用真峰值
+func replayGainFields(track, album codec.Loudness, reference float64) []codec.TagField {
+	fields := []codec.TagField{
+		{Name: "REPLAYGAIN_TRACK_GAIN", Value: fmt.Sprintf("%.2f dB", reference-track.Integrated)},
+		{Name: "REPLAYGAIN_TRACK_PEAK", Value: fmt.Sprintf("%.6f", track.TruePeak)},
+	}
+	if !math.IsInf(album.Integrated, -1) {
+		fields = append(fields,
+			codec.TagField{Name: "REPLAYGAIN_ALBUM_GAIN", Value: fmt.Sprintf("%.2f dB", reference-album.Integrated)},
+			codec.TagField{Name: "REPLAYGAIN_ALBUM_PEAK", Value: fmt.Sprintf("%.6f", album.TruePeak)},
+		)
+	}
+	return fields
+}
+
+// formatLUFS 格式化响度值，静音时显示为 -inf
+func formatLUFS(v float64) string {
+	if math.IsInf(v, -1) {
+		return "-inf"
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+func formatGain(reference, integrated float64) string {
+	if math.IsInf(integrated, -1) {
+		return "-"
+	}
+	return fmt.Sprintf("%+.2f", reference-integrated)
+}
+
+// writeLoudnessReport 按目录分组输出文本报告，每个目录先列出专辑值
+func writeLoudnessReport(out io.Writer, results []loudnessResult, albums map[string]codec.Loudness, reference float64) error {
+	var w *tabwriter.Writer
+	dir := ""
+	for _, r := range results {
+		if d := filepath.Dir(r.path); w == nil || d != dir {
+			if w != nil {
+				if err := w.Flush(); err != nil {
+					return err
+				}
+			}
+			dir = d
+			fmt.Fprintf(out, "\n%s\n", dir)
+			w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "  文件\t响度 (LUFS)\tLRA (LU)\t真峰值 (dBTP)\t增益 (dB)")
+			if album, ok := albums[dir]; ok {
+				fmt.Fprintf(w, "  [专辑]\t%s\t%.1f\t%.1f\t%s\n",
+					formatLUFS(album.Integrated), album.Range, album.TruePeakDB(), formatGain(reference, album.Integrated))
+			}
+		}
+		name := filepath.Base(r.path)
+		if r.err != nil {
+			fmt.Fprintf(w, "  %s\t错误：%v\n", name, r.err)
+			continue
+		}
+		l := r.loudness
+		fmt.Fprintf(w, "  %s\t%s\t%.1f\t%.1f\t%s\n",
+			name, formatLUFS(l.Integrated), l.Range, l.TruePeakDB(), formatGain(reference, l.Integrated))
+	}
+	if w == nil {
+		return nil
+	}
+	return w.Flush()
+}
+
+func writeLoudnessCSV(out io.Writer, results []loudnessResult, albums map[string]codec.Loudness, reference float64) error {
+	w := csv.NewWriter(out)
+	_ = w.Write([]string{
+		"path", "duration", "integrated_lufs", "range_lu", "true_peak_dbtp", "track_gain_db",
+		"album_integrated_lufs", "album_range_lu", "album_true_peak_dbtp", "album_gain_db", "error",
+	})
+	for _, r := range results {
+		if r.err != nil {
+			_ = w.Write([]string{r.path, "", "", "", "", "", "", "", "", "", r.err.Error()})
+			continue
+		}
+		l, album := r.loudness, albums[filepath.Dir(r.path)]
+		_ = w.Write([]string{
+			r.path,
+			fmt.Sprintf("%.3f", r.duration.Seconds()),
+			formatLUFS(l.Integrated),
+			fmt.Sprintf("%.1f", l.Range),
+			fmt.Sprintf("%.2f", l.TruePeakDB()),
+			formatGain(reference, l.Integrated),
+			formatLUFS(album.Integrated),
+			fmt.Sprintf("%.1f", album.Range),
+			fmt.Sprintf("%.2f", album.TruePeakDB()),
+			formatGain(reference, album.Integrated),
+			"",
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,120 @@
+package player
+
+import (
+	"bytes"
+	"math"
+	"music-cli/codec"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dhowden/tag"
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+)
+
+func TestLoudnessPathsCue(t *testing.T) {
+	dir := t.TempDir()
+	image := writeTone(t, "image.wav", time.Second, [2]float64{0.5, 0.5})
+	if err := os.Rename(image, filepath.Join(dir, "image.wav")); err != nil {
+		t.Fatal(err)
+	}
+	single := writeTone(t, "single.wav", time.Second, [2]float64{0.5, 0.5})
+	if err := os.Rename(single, filepath.Join(dir, "single.wav")); err != nil {
+		t.Fatal(err)
+	}
+	cue := `FILE "image.wav" WAVE
+  TRACK 01 AUDIO
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    INDEX 01 00:00:40
+`
+	if err := os.WriteFile(filepath.Join(dir, "album.cue"), []byte(cue), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := loudnessPaths([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// CUE 的两条音轨换成同一个整轨文件，只测量一次
+	slices.Sort(paths)
+	want := []string{filepath.Join(dir, "image.wav"), filepath.Join(dir, "single.wav")}
+	if !slices.Equal(paths, want) {
+		t.Errorf("got %v, want %v", paths, want)
+	}
+	for _, path := range paths {
+		if r := measureLoudness(path); r.err != nil {
+			t.Errorf("%s: %v", path, r.err)
+		}
+	}
+}
+
+// writeSine 在 dir 下写一个 1 秒的 1 kHz 正弦波，按扩展名编码成 WAV 或 FLAC
+func writeSine(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	format := beep.Format{SampleRate: outputSampleRate, NumChannels: 2, Precision: 2}
+	n := 0
+	sine := beep.Take(outputSampleRate.N(time.Second), beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		for i := range samples {
+			v := 0.25 * math.Sin(2*math.Pi*1000*float64(n)/float64(outputSampleRate))
+			samples[i] = [2]float64{v, v}
+			n++
+		}
+		return len(samples), true
+	}))
+	if filepath.Ext(name) == ".flac" {
+		err = codec.EncodeFLAC(f, sine, format)
+	} else {
+		err = wav.Encode(f, sine, format)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoudnessWriteMixedFormats(t *testing.T) {
+	dir := t.TempDir()
+	flacPath := writeSine(t, dir, "a.flac")
+	wavPath := writeSine(t, dir, "b.wav")
+	wavData, err := os.ReadFile(wavPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := loudnessPaths([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := measureAll(paths, 2)
+	written, skipped, failed := writeReplayGain(results, albumLoudness(results), replayGainReference)
+	// WAV 不支持写标签，算作跳过而不是失败
+	if written != 1 || skipped != 1 || failed != 0 {
+		t.Errorf("written %d, skipped %d, failed %d, want 1, 1, 0", written, skipped, failed)
+	}
+
+	f, err := os.Open(flacPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := tag.ReadFrom(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Raw()["replaygain_track_gain"]; !ok {
+		t.Errorf("FLAC file has no track gain: %v", m.Raw())
+	}
+	if data, err := os.ReadFile(wavPath); err != nil || !bytes.Equal(data, wavData) {
+		t.Errorf("WAV file changed (%v)", err)
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"time"
 )
 
+// redrawIndex 不对应任何歌词行，把 currentIndex 设为它会触发整屏重绘
+const redrawIndex = -2
+
 // lyrics 管理解析后的歌词行对（原文 + 译文）
 type lyrics struct {
 	pairs        []lyricPair
@@ -152,7 +155,7 @@ func parseWord(wordInfo []string) (word, error) {
 	}, nil
 }
 
-func (l *lyrics) print(wg *sync.WaitGroup, player *Player, clearChan chan struct{}) {
+func (l *lyrics) print(wg *sync.WaitGroup, player *Player, done chan struct{}, clearChan chan struct{}) {
 	defer wg.Done()
 
 	ticker := time.NewTicker(10 * time.Millisecond)
@@ -161,43 +164,36 @@ func (l *lyrics) print(wg *sync.WaitGroup, player *Player, clearChan chan struct
 	var nextLine lyricPair
 	var lIndex int
 	var currentLine lyricPair
-	var once sync.Once
 	lastWIndex := -1
 	wIndex := -1
+	// 强制第一次刷新时整屏重绘
+	l.currentIndex = redrawIndex
 
 	for {
 		select {
-		case <-player.done:
+		case <-done:
 			return
 		case <-clearChan:
 			fmt.Print(utils.Center(fmt.Sprintf("[%d]: %s - %s", player.id, player.metadata.Artist(), player.metadata.Title())))
 			l.printLastLyric(lastLine)
 			l.printCurrentLyric(currentLine, wIndex, true, false)
 			l.printNextLyric(nextLine)
+		case <-player.resync:
+			// 跳转后行号和逐字位置都可能变化，下一次刷新时整屏重绘
+			l.currentIndex = redrawIndex
+			wIndex = -1
 		case <-ticker.C:
 			currentTime := player.getCurrentTime()
 			lastWIndex = wIndex
 			lIndex, currentLine = l.getCurrentLyric(currentTime)
 			wIndex, _ = getCurrentWord(currentLine.Original, currentTime)
-			if lIndex == -1 && len(l.pairs) > 0 {
-				once.Do(func() {
-					l.printCurrentLyric(lyricPair{
-						Original:   lyricLine{Text: ""},
-						Translated: lyricLine{Text: ""},
-					}, -1, true, false)
-					l.printNextLyric(l.pairs[0])
-				})
-			}
+			lastLine = lyricPair{}
 			if lIndex-1 >= 0 {
 				lastLine = l.pairs[lIndex-1]
 			}
+			nextLine = lyricPair{}
 			if lIndex+1 < len(l.pairs) {
 				nextLine = l.pairs[lIndex+1]
-			} else if lIndex+1 == len(l.pairs) {
-				nextLine = lyricPair{
-					Original:   lyricLine{Text: ""},
-					Translated: lyricLine{Text: ""},
-				}
 			}
 			if lIndex != l.currentIndex {
 				l.printLastLyric(lastLine)
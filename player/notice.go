@@ -0,0 +1,32 @@
+package player
+
+import (
+	"sync"
+	"time"
+)
+
+// 提示信息在状态栏显示一段时间后自动消失
+const noticeDuration = 3 * time.Second
+
+var (
+	noticeMu      sync.Mutex
+	notice        string
+	noticeExpires time.Time
+)
+
+// showNotice 在状态栏显示一条临时提示
+func showNotice(text string) {
+	noticeMu.Lock()
+	defer noticeMu.Unlock()
+	notice = text
+	noticeExpires = time.Now().Add(noticeDuration)
+}
+
+func noticeText() string {
+	noticeMu.Lock()
+	defer noticeMu.Unlock()
+	if time.Now().After(noticeExpires) {
+		return ""
+	}
+	return notice
+}
@@ -0,0 +1,84 @@
+package player
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/speaker"
+)
+
+// Output 是音频输出后端，播放队列通过它送出采样
+// 播放相关的代码修改 streamer 链时都要先 Lock，和后端读取采样的 goroutine 互斥
+type Output interface {
+	// Start 打开输出并开始不断从 s 读取采样，只会被调用一次
+	Start(sampleRate beep.SampleRate, bufferSize int, s beep.Streamer) error
+	Lock()
+	Unlock()
+	// Close 停止读取并释放资源，文件类的后端在这里写完文件
+	Close() error
+}
+
+// output 是当前使用的后端，默认是声卡
+var output Output = speakerOutput{}
+
+// SetOutput 替换输出后端，必须在开始播放之前调用
+func SetOutput(o Output) {
+	output = o
+}
+
+// ParseOutput 按名字创建输出后端
+//
+//	speaker          声卡（默认）
+//	null             丢弃采样，按实际播放速度消耗
+//	null-fast        丢弃采样，尽可能快地消耗
+//	wav=FILE         按实际播放速度写入 WAV 文件
+//	wav-fast=FILE    尽可能快地写入 WAV 文件
+func ParseOutput(spec string) (Output, error) {
+	name, arg, _ := strings.Cut(spec, "=")
+	switch name {
+	case "", "speaker":
+		return speakerOutput{}, nil
+	case "null":
+		return NewNullOutput(true), nil
+	case "null-fast":
+		return NewNullOutput(false), nil
+	case "wav", "wav-fast":
+		if arg == "" {
+			return nil, fmt.Errorf("output %s needs a file name, e.g. %s=out.wav", name, name)
+		}
+		return NewWAVOutput(arg, name == "wav"), nil
+	}
+	return nil, fmt.Errorf("unknown output: %s", spec)
+}
+
+// exit 关闭输出后端后退出程序，保证 WAV 文件写完整
+func exit(code int) {
+	_ = output.Close()
+	os.Exit(code)
+}
+
+// speakerOutput 通过 beep/speaker 输出到声卡
+type speakerOutput struct{}
+
+func (speakerOutput) Start(sampleRate beep.SampleRate, bufferSize int, s beep.Streamer) error {
+	if err := speaker.Init(sampleRate, bufferSize); err != nil {
+		return err
+	}
+	speaker.Play(s)
+	return nil
+}
+
+func (speakerOutput) Lock() {
+	speaker.Lock()
+}
+
+func (speakerOutput) Unlock() {
+	speaker.Unlock()
+}
+
+func (speakerOutput) Close() error {
+	speaker.Close()
+	return nil
+}
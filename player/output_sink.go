@@ -0,0 +1,154 @@
+package player
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+)
+
+// 快速模式下队列为空时的等待间隔
+const sinkIdleWait = 10 * time.Millisecond
+
+// idler 由播放队列实现，快速模式下队列为空时不需要消耗静音
+type idler interface {
+	idle() bool
+}
+
+// sink 是不依赖声卡的后端的公共部分，在自己的 goroutine 里一块一块地读取采样
+// realtime 为 true 时按采样率控制读取速度，和真实的声卡一样
+type sink struct {
+	mu         sync.Mutex
+	realtime   bool
+	sampleRate beep.SampleRate
+	streamer   beep.Streamer
+	next       time.Time // 实时模式下一块采样应该读取的时间
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	finished  chan struct{}
+	err       error
+}
+
+func newSink(realtime bool) sink {
+	return sink{
+		realtime: realtime,
+		closed:   make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+}
+
+func (k *sink) Lock() {
+	k.mu.Lock()
+}
+
+func (k *sink) Unlock() {
+	k.mu.Unlock()
+}
+
+// Stream 读取一块采样，Close 之后返回 false
+func (k *sink) Stream(samples [][2]float64) (n int, ok bool) {
+	select {
+	case <-k.closed:
+		return 0, false
+	default:
+	}
+
+	k.mu.Lock()
+	if q, isIdler := k.streamer.(idler); !k.realtime && isIdler && q.idle() {
+		k.mu.Unlock()
+		time.Sleep(sinkIdleWait)
+		return 0, true
+	}
+	n, ok = k.streamer.Stream(samples)
+	k.mu.Unlock()
+
+	if k.realtime {
+		now := time.Now()
+		if k.next.Before(now.Add(-time.Second)) {
+			// 落后太多（例如系统休眠过）时不追赶
+			k.next = now
+		}
+		k.next = k.next.Add(k.sampleRate.D(n))
+		time.Sleep(time.Until(k.next))
+	}
+	return n, ok
+}
+
+func (k *sink) Err() error {
+	return nil
+}
+
+// run 在单独的 goroutine 里执行 consume，直到 Close
+func (k *sink) run(sampleRate beep.SampleRate, s beep.Streamer, consume func() error) {
+	k.sampleRate = sampleRate
+	k.streamer = s
+	k.next = time.Now()
+	go func() {
+		defer close(k.finished)
+		k.err = consume()
+	}()
+}
+
+func (k *sink) Close() error {
+	started := k.streamer != nil
+	k.closeOnce.Do(func() { close(k.closed) })
+	if !started {
+		return nil
+	}
+	<-k.finished
+	return k.err
+}
+
+// nullOutput 丢弃所有采样，用于没有声卡的环境
+type nullOutput struct {
+	sink
+}
+
+// NewNullOutput 创建丢弃采样的后端，realtime 为 false 时尽可能快地播放
+func NewNullOutput(realtime bool) Output {
+	return &nullOutput{sink: newSink(realtime)}
+}
+
+func (o *nullOutput) Start(sampleRate beep.SampleRate, bufferSize int, s beep.Streamer) error {
+	buf := make([][2]float64, bufferSize)
+	o.run(sampleRate, s, func() error {
+		for {
+			if _, ok := o.Stream(buf); !ok {
+				return nil
+			}
+		}
+	})
+	return nil
+}
+
+// wavOutput 把输出写入 16 位立体声 WAV 文件
+type wavOutput struct {
+	sink
+	path string
+}
+
+// NewWAVOutput 创建写入 WAV 文件的后端，realtime 为 false 时尽可能快地写入
+// 快速模式下队列为空的时间不会写进文件
+func NewWAVOutput(path string, realtime bool) Output {
+	return &wavOutput{sink: newSink(realtime), path: path}
+}
+
+func (o *wavOutput) Start(sampleRate beep.SampleRate, bufferSize int, s beep.Streamer) error {
+	f, err := os.Create(o.path)
+	if err != nil {
+		return err
+	}
+	format := beep.Format{SampleRate: sampleRate, NumChannels: 2, Precision: 2}
+	o.run(sampleRate, s, func() error {
+		// wav.Encode 在 Stream 返回 false 后回填文件头中的长度
+		err := wav.Encode(f, &o.sink, format)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	})
+	return nil
+}
@@ -0,0 +1,83 @@
+package player
+
+import (
+	"fmt"
+	"sync"
+)
+
+// panelRow 是设置面板的第一行，位于进度条下方
+const panelRow = 14
+
+// panel 是显示在进度条下方的设置面板，打开时接管播放界面的按键
+type panel interface {
+	// handleKey 处理按键，返回 false 表示面板应当关闭
+	handleKey(k key, players []*Player) bool
+	// lines 返回面板当前要显示的内容
+	lines() []string
+}
+
+var (
+	panelMu     sync.Mutex
+	activePanel panel
+	panelDirty  bool
+	panelHeight int // 上一次绘制的行数，关闭或变短时用来清除多余的行
+)
+
+func openPanel(p panel) {
+	panelMu.Lock()
+	defer panelMu.Unlock()
+	activePanel = p
+	panelDirty = true
+}
+
+func closePanel() {
+	panelMu.Lock()
+	defer panelMu.Unlock()
+	activePanel = nil
+	panelDirty = true
+}
+
+// redrawPanel 标记面板需要重绘，例如清屏之后
+func redrawPanel() {
+	panelMu.Lock()
+	defer panelMu.Unlock()
+	panelDirty = true
+}
+
+// handlePanelKey 把按键交给打开的面板，没有面板时返回 false
+func handlePanelKey(k key, players ...*Player) bool {
+	panelMu.Lock()
+	p := activePanel
+	panelMu.Unlock()
+	if p == nil {
+		return false
+	}
+	if !p.handleKey(k, players) {
+		closePanel()
+	} else {
+		redrawPanel()
+	}
+	return true
+}
+
+// drawPanel 在需要时重绘面板，调用方需持有 printMu
+func drawPanel() {
+	panelMu.Lock()
+	defer panelMu.Unlock()
+	if !panelDirty {
+		return
+	}
+	panelDirty = false
+
+	var lines []string
+	if activePanel != nil {
+		lines = activePanel.lines()
+	}
+	for i, line := range lines {
+		fmt.Printf("\033[%d;1H\033[2K%s\x1b[0m", panelRow+i, line)
+	}
+	for i := len(lines); i < panelHeight; i++ {
+		fmt.Printf("\033[%d;1H\033[2K", panelRow+i)
+	}
+	panelHeight = len(lines)
+}
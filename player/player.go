@@ -2,19 +2,18 @@ package player
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
+	"music-cli/codec"
 	"music-cli/utils"
 	"os"
-	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dhowden/tag"
 	"github.com/faiface/beep"
-	"github.com/faiface/beep/flac"
-	"github.com/faiface/beep/mp3"
-	"github.com/faiface/beep/speaker"
-	"github.com/faiface/beep/wav"
+	"github.com/faiface/beep/effects"
 	"golang.org/x/term"
 )
 
@@ -78,121 +77,245 @@ type Player struct {
 	// 核心播放组件
 	streamer beep.StreamSeekCloser
 	format   beep.Format
-	ctrl     *beep.Ctrl // 新增：用于控制暂停/继续
+	skip     *silenceSkipper // 跳过静音，就是 streamer 本身
+	ab       *abLoop         // A-B 循环
+	ctrl     *beep.Ctrl      // 新增：用于控制暂停/继续
+	speed    *beep.Resampler // 变速
+	eq       *equalizer
+	gain     *effects.Gain // ReplayGain
+	volume   *effects.Volume
+	channels *channelMixer // 平衡、单声道、左右互换
+	tap      *tap          // 可视化的采样来源
 
 	// 元数据
 	path     string
+	cue      *utils.CueTrack // CUE 虚拟音轨，普通文件为 nil
+	title    string          // 播放列表中给出的标题，文件没有标签时使用
 	file     *os.File
 	metadata tag.Metadata
+	rg       replayGain
+	analysis atomic.Pointer[codec.Analysis] // 速度和调性，后台分析完成前为 nil
+	// 是否按目录顺序播放，ReplayGain 自动模式据此选择专辑或单曲增益
+	sequential bool
+	// 继续播放时开始的位置，排进队列时使用一次
+	resumeAt    time.Duration
+	resumedFrom time.Duration // 实际从哪里开始播放，用于提示
 	// UI组件
 	pb    *progressBar
 	lyric *lyrics
 
+	// 播放队列中的位置，为 nil 表示还没有排队
+	entry *queueEntry
+
 	// 状态管理
 	isPaused  bool
 	mu        sync.Mutex
 	done      chan struct{}
 	closeOnce sync.Once
+	resync    chan struct{} // 跳转后通知歌词重新定位
 }
 
 func NewPlayer(path string, id int) *Player {
 	return &Player{
-		path:  path,
-		lyric: newLyrics(nil),
-		done:  make(chan struct{}),
-		id:    id,
+		path:   path,
+		lyric:  newLyrics(nil),
+		done:   make(chan struct{}),
+		resync: make(chan struct{}, 1),
+		id:     id,
 	}
 }
 
 func (p *Player) Init() error {
-	f, err := os.Open(p.path)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 同一个 Player 可能被重新初始化（例如列表循环），先释放上一次的资源
+	p.close()
+	p.done = make(chan struct{})
+	p.closeOnce = sync.Once{}
+
+	// CUE 虚拟音轨实际播放的是整轨文件中的一段
+	p.cue = nil
+	if _, _, ok := utils.SplitCueTrackPath(p.path); ok {
+		track, err := utils.LoadCueTrack(p.path)
+		if err != nil {
+			return err
+		}
+		p.cue = track
+	}
+
+	f, err := os.Open(p.audioPath())
 	if err != nil {
 		return err
 	}
 	p.file = f
 	p.lyric = newLyrics(nil)
 	p.LoadLyric()
-	switch filepath.Ext(p.path) {
-	case ".mp3":
-		streamer, format, err := mp3.Decode(f)
-		if err != nil {
-			return err
-		}
-		p.streamer = streamer
-		p.format = format
-	case ".flac":
-		streamer, format, err := flac.Decode(f)
-		if err != nil {
-			return err
-		}
-		p.streamer = streamer
-		p.format = format
-	case ".wav":
-		streamer, format, err := wav.Decode(f)
+	streamer, format, err := codec.Decode(p.audioPath(), f)
+	if err != nil {
+		return err
+	}
+	if p.cue != nil {
+		bounded, err := newSection(streamer, format, p.cue)
 		if err != nil {
+			_ = streamer.Close()
+			p.file = nil
 			return err
 		}
-		p.streamer = streamer
-		p.format = format
-	default:
-		return fmt.Errorf("unsupported audio format: %s", filepath.Ext(p.path))
+		streamer = bounded
 	}
-
-	p.ctrl = &beep.Ctrl{Streamer: p.streamer}
+	p.skip = newSilenceSkipper(streamer, format.SampleRate)
+	p.streamer = p.skip
+	p.format = format
+
+	p.ab = newABLoop(p.streamer, p.resync)
+	p.ctrl = &beep.Ctrl{Streamer: p.ab}
+	p.speed = newSpeed(p.ctrl)
+	p.eq = newEqualizer(p.speed, p.format.SampleRate)
+	p.gain = p.newReplayGain(p.eq)
+	p.volume = newVolume(p.gain)
+	p.channels = newChannelMixer(p.volume)
+	p.tap = newTap(p.channels, p.format.SampleRate)
 	p.isPaused = false
 
-	p.done = make(chan struct{})
-	p.closeOnce = sync.Once{}
-
 	return nil
 }
 
+// audioPath 返回实际要解码的音频文件路径
+func (p *Player) audioPath() string {
+	if p.cue != nil {
+		return p.cue.File
+	}
+	return p.path
+}
+
 func (p *Player) LoadLyric() {
-	file, err := os.Open(p.path)
+	p.metadata = &defaultMetadata{}
+	if p.cue != nil {
+		p.metadata = &cueMetadata{Metadata: p.metadata, track: p.cue}
+	} else if p.title != "" {
+		p.metadata = newPlaylistMetadata(p.title)
+	}
+	file, err := os.Open(p.audioPath())
 	if err != nil {
 		return
 	}
 	defer file.Close()
 
+	p.rg = replayGain{}
 	meta, err := tag.ReadFrom(file)
 	if err != nil {
-		p.metadata = &defaultMetadata{}
 		return
 	}
 	if meta == nil {
-		p.metadata = &defaultMetadata{}
 		return
 	}
+	if p.cue != nil {
+		meta = &cueMetadata{Metadata: meta, track: p.cue}
+	}
 	p.metadata = meta
+	p.rg = readReplayGain(meta)
 	lyricData := meta.Lyrics()
 	p.lyric.parse(lyricData)
 }
 
+// enqueue 把曲目重采样到输出采样率后接到播放队列末尾
+// 重复调用不会重复排队，预加载的下一首会在当前曲目结束时无缝接上
+// crossfade 为 true 时，开启淡入淡出后会与前一首交叉混合
+func (p *Player) enqueue(crossfade bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.entry != nil {
+		return nil
+	}
+	if p.format.SampleRate == 0 || p.tap == nil || p.done == nil {
+		return fmt.Errorf("player not initialized: %s", p.path)
+	}
+	p.seekToResume()
+
+	done := p.done
+	streamer := p.streamer
+	format := p.format
+	ab := p.ab
+	resampled := beep.Resample(resampleQuality, format.SampleRate, outputSampleRate, p.tap)
+	entry := &queueEntry{
+		streamer: beep.Seq(resampled, beep.Callback(func() {
+			p.closeOnce.Do(func() { close(done) })
+		})),
+		ctrl: p.ctrl,
+		// 变速后实际播放的剩余时长是媒体时长除以倍率，这里在输出后端的锁内被调用
+		// A-B 循环时曲目不会结束，不能开始淡出
+		remaining: func() int {
+			if ab.active() {
+				return math.MaxInt32
+			}
+			left := outputSampleRate.N(format.SampleRate.D(streamer.Len() - streamer.Position()))
+			return int(float64(left) / playbackSpeed)
+		},
+		crossfade: crossfade,
+	}
+	if err := queue.push(entry); err != nil {
+		return err
+	}
+	p.entry = entry
+	return nil
+}
+
+// seekToResume 从上次停下的位置开始播放，调用方需持有 p.mu，且曲目还没有排进队列
+// 优先使用继续播放记录中的位置，其次是长文件单独记住的位置
+func (p *Player) seekToResume() {
+	start := p.resumeAt
+	p.resumeAt = 0
+	p.resumedFrom = 0
+	total := p.format.SampleRate.D(p.streamer.Len())
+	if start == 0 && total >= longTrackDuration {
+		start, _ = savedPositionOf(p.path)
+	}
+	if start <= 0 || start >= total {
+		return
+	}
+	if err := p.streamer.Seek(p.format.SampleRate.N(start)); err == nil {
+		p.resumedFrom = start
+	}
+}
+
 func (p *Player) Play() {
+	if err := p.enqueue(false); err != nil {
+		return
+	}
+
 	p.mu.Lock()
 	format := p.format
 	streamer := p.streamer
-	ctrl := p.ctrl
 	done := p.done
+	resumedFrom := p.resumedFrom
 	p.mu.Unlock()
 
-	if format.SampleRate == 0 || streamer == nil || ctrl == nil || done == nil {
-		return
+	if resumedFrom > 0 {
+		seconds := int(resumedFrom.Seconds())
+		showNotice(fmt.Sprintf("从 %02d:%02d 继续播放", seconds/60, seconds%60))
 	}
 
-	speaker.Init(format.SampleRate, format.SampleRate.N(time.Second)/10)
+	if format.SampleRate == 0 || streamer == nil || done == nil {
+		return
+	}
 
 	totalTime := time.Duration(streamer.Len()) * time.Second / time.Duration(format.SampleRate)
 	p.pb = newProgressBar(totalTime)
+	go p.loadWaveform(p.pb, done)
+	go p.loadAnalysis(done)
 
-	speaker.Play(beep.Seq(ctrl, beep.Callback(func() {
-		p.closeOnce.Do(func() { close(done) })
-	})))
-
-	go p.displayLoop()
+	go p.displayLoop(done)
 
 	<-done
-	p.Close()
+
+	// 播放期间 Player 可能已经被重新初始化，这时资源归新的一轮播放所有，不能关闭
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done == done {
+		p.close()
+	}
 }
 
 func (p *Player) TogglePause() {
@@ -203,31 +326,75 @@ func (p *Player) TogglePause() {
 		return
 	}
 
-	speaker.Lock()
+	output.Lock()
 	p.ctrl.Paused = !p.isPaused
-	speaker.Unlock()
+	output.Unlock()
 	p.isPaused = !p.isPaused
 
 }
 
-func (p *Player) displayLoop() {
+// seek 以当前位置为基准前后跳转 offset，超出范围时停在开头或结尾
+func (p *Player) seek(offset time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.streamer == nil {
+		return
+	}
+
+	output.Lock()
+	// 长度为 0（空的或长度未知的流）时停在开头，不能跳到负的位置
+	pos := p.streamer.Position() + p.format.SampleRate.N(offset)
+	pos = max(0, min(pos, p.streamer.Len()-1))
+	err := p.streamer.Seek(pos)
+	output.Unlock()
+	if err != nil {
+		return
+	}
+
+	select {
+	case p.resync <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Player) displayLoop(done chan struct{}) {
 	fmt.Print("\x1b[?25l")
 	fmt.Print("\033[2J\033[H")
 	defer fmt.Print("\x1b[?25h")
-	fmt.Print(utils.Center(fmt.Sprintf("[%d]: %s - %s", p.id, p.metadata.Artist(), p.metadata.Title())))
+	redrawPanel()
+	p.printTitle()
 	wg := sync.WaitGroup{}
-	wg.Add(3)
+	wg.Add(4)
 	var clearChan = make(chan struct{})
-	go clearScreen(&wg, p, clearChan)
-	go p.pb.printBar(&wg, p)
-	go p.lyric.print(&wg, p, clearChan)
+	go clearScreen(&wg, done, clearChan)
+	go p.pb.printBar(&wg, p, done)
+	go p.lyric.print(&wg, p, done, clearChan)
+	go p.printVisualizer(&wg, done)
 	wg.Wait()
 }
 
 func (p *Player) Close() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.close()
+}
 
+// close 释放解码器和文件，调用方需持有 p.mu
+func (p *Player) close() {
+	if p.entry != nil {
+		queue.remove(p.entry)
+		p.entry = nil
+	}
+	p.skip = nil
+	p.ab = nil
+	p.ctrl = nil
+	p.speed = nil
+	p.eq = nil
+	p.gain = nil
+	p.volume = nil
+	p.channels = nil
+	p.tap = nil
 	if p.streamer != nil {
 		_ = p.streamer.Close()
 		p.streamer = nil
@@ -243,7 +410,41 @@ func (p *Player) Close() {
 	})
 }
 
+// statusText 返回显示在进度条右侧的状态信息
+func (p *Player) statusText() string {
+	status := volumeText() + "  " + repeatText()
+	if text := speedText(); text != "" {
+		status += "  " + text
+	}
+	if text := eqText(); text != "" {
+		status += "  " + text
+	}
+	if text := skipSilenceText(); text != "" {
+		status += "  " + text
+	}
+	if text := channelsText(); text != "" {
+		status += "  " + text
+	}
+	if text := replayGainText(); text != "" {
+		status += "  " + text
+	}
+	if text := crossfadeText(); text != "" {
+		status += "  " + text
+	}
+	if text := sleepText(p); text != "" {
+		status += "  " + text
+	}
+	if text := noticeText(); text != "" {
+		status += "  " + text
+	}
+	return status
+}
+
+// getCurrentTime 返回当前的播放位置，曲目已经关闭时返回 0
+// 播放结束时 Play 的 goroutine 会关闭曲目，所以要持有 p.mu 读取
 func (p *Player) getCurrentTime() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if p.streamer != nil {
 		return time.Duration(p.streamer.Position()) * time.Second / time.Duration(p.format.SampleRate)
 	}
@@ -254,6 +455,7 @@ func getPlayerList(paths []string) []*Player {
 	var players []*Player
 	for i, path := range paths {
 		player := NewPlayer(path, i+1)
+		player.sequential = true
 		players = append(players, player)
 	}
 	return players
@@ -268,11 +470,12 @@ func randomPlayer(players []*Player) []*Player {
 	})
 	for i, player := range players {
 		player.id = i + 1
+		player.sequential = false
 	}
 	return players
 }
 
-func clearScreen(wg *sync.WaitGroup, player *Player, clearChan chan struct{}) {
+func clearScreen(wg *sync.WaitGroup, done chan struct{}, clearChan chan struct{}) {
 	defer wg.Done()
 
 	ticker := time.NewTicker(time.Millisecond * 100)
@@ -282,7 +485,7 @@ func clearScreen(wg *sync.WaitGroup, player *Player, clearChan chan struct{}) {
 
 	for {
 		select {
-		case <-player.done:
+		case <-done:
 			return
 		case <-ticker.C:
 
@@ -292,6 +495,7 @@ func clearScreen(wg *sync.WaitGroup, player *Player, clearChan chan struct{}) {
 				printMu.Lock()
 				fmt.Print("\033[2J\033[H")
 				printMu.Unlock()
+				redrawPanel()
 				clearChan <- struct{}{}
 			}
 
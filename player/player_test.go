@@ -0,0 +1,76 @@
+package player
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// fakeStreamer 只记录跳转的位置
+type fakeStreamer struct {
+	length, pos int
+}
+
+func (s *fakeStreamer) Stream(samples [][2]float64) (int, bool) { return 0, false }
+func (s *fakeStreamer) Err() error                              { return nil }
+func (s *fakeStreamer) Len() int                                { return s.length }
+func (s *fakeStreamer) Position() int                           { return s.pos }
+func (s *fakeStreamer) Close() error                            { return nil }
+
+func (s *fakeStreamer) Seek(p int) error {
+	// 和 beep 的解码器不同，这里接受任何位置，越界的跳转会留在 pos 里
+	s.pos = p
+	return nil
+}
+
+func TestSeekClamp(t *testing.T) {
+	useOutput(t, NewNullOutput(false))
+	const sr = beep.SampleRate(44100)
+	second := sr.N(time.Second)
+	tests := []struct {
+		name   string
+		length int
+		pos    int
+		offset time.Duration
+		want   int
+	}{
+		{"forward", second, 0, 500 * time.Millisecond, second / 2},
+		{"backward", second, second / 2, -250 * time.Millisecond, second / 4},
+		{"past the end", second, 0, 10 * time.Second, second - 1},
+		{"before the start", second, second / 2, -10 * time.Second, 0},
+		{"empty stream", 0, 0, 5 * time.Second, 0},
+		{"empty stream backward", 0, 0, -5 * time.Second, 0},
+	}
+	for i, tt := range tests {
+		s := &fakeStreamer{length: tt.length, pos: tt.pos}
+		p := NewPlayer(fmt.Sprintf("track%d.wav", i), i+1)
+		p.streamer, p.format = s, beep.Format{SampleRate: sr, NumChannels: 2, Precision: 2}
+		p.seek(tt.offset)
+		if s.pos != tt.want {
+			t.Errorf("%s: seeked to %d, want %d", tt.name, s.pos, tt.want)
+		}
+	}
+}
+
+func TestCurrentTimeWhileClosing(t *testing.T) {
+	useOutput(t, NewNullOutput(false))
+	path := writeTone(t, "a.wav", time.Second, [2]float64{0.5, 0.5})
+	// 退出时保存进度和播放结束时关闭曲目同时发生，go test -race 下不能报数据竞争
+	for i := range 20 {
+		p := newTestPlayer(t, path, i+1)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range 100 {
+				p.getCurrentTime()
+			}
+		}()
+		p.Close()
+		<-done
+		if pos := p.getCurrentTime(); pos != 0 {
+			t.Errorf("closed player reports position %v", pos)
+		}
+	}
+}
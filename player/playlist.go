@@ -0,0 +1,138 @@
+package player
+
+import (
+	"fmt"
+	"music-cli/codec"
+	"music-cli/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+)
+
+// loadPlaylist 读取播放列表并创建 Player 列表
+// 不存在或无法播放的条目不会中断加载，而是收集起来返回给调用方提示
+func loadPlaylist(path string) ([]*Player, []string, error) {
+	entries, err := utils.ParsePlaylist(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var players []*Player
+	var skipped []string
+	add := func(path, title string) {
+		player := NewPlayer(path, len(players)+1)
+		player.sequential = true
+		player.title = title
+		players = append(players, player)
+	}
+	for _, entry := range entries {
+		// music-cli 自己保存的列表里可能有 CUE 虚拟音轨
+		if cuePath, _, ok := utils.SplitCueTrackPath(entry.Path); ok {
+			if _, err := utils.LoadCueTrack(entry.Path); err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s（%s 无法读取）", entry.Path, filepath.Base(cuePath)))
+				continue
+			}
+			add(entry.Path, entry.Title)
+			continue
+		}
+		info, err := os.Stat(entry.Path)
+		switch {
+		case err != nil:
+			skipped = append(skipped, fmt.Sprintf("%s（不存在）", entry.Path))
+		case info.IsDir():
+			skipped = append(skipped, fmt.Sprintf("%s（是目录）", entry.Path))
+		case utils.IsCue(entry.Path):
+			// CUE 文件展开成其中的全部音轨
+			tracks, _, err := utils.ListDir(entry.Path)
+			if err != nil || len(tracks) == 0 {
+				skipped = append(skipped, fmt.Sprintf("%s（CUE 无法解析）", entry.Path))
+				continue
+			}
+			for _, track := range tracks {
+				add(track, "")
+			}
+		case !codec.IsSupported(entry.Path):
+			skipped = append(skipped, fmt.Sprintf("%s（不支持的格式）", entry.Path))
+		default:
+			add(entry.Path, entry.Title)
+		}
+	}
+	return players, skipped, nil
+}
+
+// saveQueue 把播放列表按当前顺序（包括随机后的顺序）保存成 m3u8 文件，返回文件路径
+// 每首曲目都要读一次标签和时长，曲目多时比较慢，调用方应放到单独的 goroutine 里
+func saveQueue(dir string, plist []*Player) (string, error) {
+	entries := make([]utils.PlaylistEntry, 0, len(plist))
+	for _, p := range plist {
+		entries = append(entries, probeEntry(p.path, p.title))
+	}
+	path := filepath.Join(dir, fmt.Sprintf("queue-%s.m3u8", time.Now().Format("20060102-150405")))
+	if err := utils.WriteM3U8(path, entries); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// probeEntry 读取曲目的标题和时长，和目录列表一样只读标签、不解码
+func probeEntry(path, title string) utils.PlaylistEntry {
+	entry := utils.PlaylistEntry{Path: path, Title: title}
+	entry.Duration, _ = utils.TrackDuration(path)
+
+	file := path
+	var cue *utils.CueTrack
+	if _, _, ok := utils.SplitCueTrackPath(path); ok {
+		if track, err := utils.LoadCueTrack(path); err == nil {
+			cue, file = track, track.File
+		}
+	}
+	var meta tag.Metadata = &defaultMetadata{}
+	if f, err := os.Open(file); err == nil {
+		if m, err := tag.ReadFrom(f); err == nil && m != nil {
+			meta = m
+		}
+		f.Close()
+	}
+	if cue != nil {
+		meta = &cueMetadata{Metadata: meta, track: cue}
+	}
+
+	switch {
+	case meta.Artist() != "" && meta.Artist() != "Unknown Artist":
+		entry.Title = meta.Artist() + " - " + meta.Title()
+	case meta.Title() != "" && meta.Title() != "Unknown Title":
+		entry.Title = meta.Title()
+	case entry.Title == "":
+		entry.Title = utils.TrackName(path)
+	}
+	return entry
+}
+
+// playlistMetadata 在文件没有标签时使用播放列表中 #EXTINF 的标题
+// 标题一般是 "艺术家 - 标题" 的形式
+type playlistMetadata struct {
+	defaultMetadata
+	artist string
+	title  string
+}
+
+func newPlaylistMetadata(title string) *playlistMetadata {
+	m := &playlistMetadata{title: title}
+	if artist, name, ok := strings.Cut(title, " - "); ok {
+		m.artist, m.title = strings.TrimSpace(artist), strings.TrimSpace(name)
+	}
+	return m
+}
+
+func (m *playlistMetadata) Title() string {
+	return m.title
+}
+
+func (m *playlistMetadata) Artist() string {
+	if m.artist == "" {
+		return m.defaultMetadata.Artist()
+	}
+	return m.artist
+}
@@ -0,0 +1,39 @@
+package player
+
+import (
+	"music-cli/utils"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProbeEntry(t *testing.T) {
+	image := writeTone(t, "image.wav", 3*time.Second, [2]float64{0.5, 0.5})
+	cue := filepath.Join(filepath.Dir(image), "album.cue")
+	sheet := "PERFORMER \"Band\"\nFILE \"image.wav\" WAVE\nTRACK 01 AUDIO\nTITLE \"One\"\nINDEX 01 00:00:00\n" +
+		"TRACK 02 AUDIO\nTITLE \"Two\"\nINDEX 01 00:01:00\n"
+	if err := os.WriteFile(cue, []byte(sheet), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		title    string
+		want     string
+		duration time.Duration
+	}{
+		{"untagged file", image, "", "image.wav", 3 * time.Second},
+		{"playlist title", image, "Artist - Song", "Artist - Song", 3 * time.Second},
+		{"cue track", utils.CueTrackPath(cue, 1), "", "Band - One", time.Second},
+		{"last cue track", utils.CueTrackPath(cue, 2), "", "Band - Two", 2 * time.Second},
+		{"missing file", filepath.Join(t.TempDir(), "gone.mp3"), "", "gone.mp3", 0},
+	}
+	for _, tt := range tests {
+		entry := probeEntry(tt.path, tt.title)
+		if entry.Path != tt.path || entry.Title != tt.want || entry.Duration.Round(time.Millisecond) != tt.duration {
+			t.Errorf("%s: got %+v, want title %q duration %v", tt.name, entry, tt.want, tt.duration)
+		}
+	}
+}
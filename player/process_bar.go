@@ -4,19 +4,27 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/mattn/go-runewidth"
 	"golang.org/x/term"
 )
 
 type progressBar struct {
 	totalTime   time.Duration
 	currentTime time.Duration
+	status      string                   // 显示在总时间右侧的状态，例如音量
+	loopA       time.Duration            // A-B 循环的 A 点，-1 表示未设置
+	loopB       time.Duration            // A-B 循环的 B 点，-1 表示未设置
+	wave        atomic.Pointer[waveform] // 后台算好的波形，算好之前显示实心的进度条
 }
 
 func newProgressBar(total time.Duration) *progressBar {
 	return &progressBar{
 		totalTime: total,
+		loopA:     -1,
+		loopB:     -1,
 	}
 }
 
@@ -33,6 +41,9 @@ func (pb *progressBar) getCurrentBar() string {
 
 	// 减去一些空间用于显示时间，防止进度条过长导致换行
 	currentBarLength := width - 17
+	if pb.status != "" {
+		currentBarLength -= runewidth.StringWidth(pb.status) + 2
+	}
 	if currentBarLength <= 0 { // 防止窗口太小时长度为负
 		currentBarLength = 1
 	}
@@ -53,19 +64,44 @@ func (pb *progressBar) getCurrentBar() string {
 	// 计算已播放的长度
 	filledLength := int(percentage / 100 * float64(currentBarLength))
 
+	markerA, markerB := pb.markerIndex(pb.loopA, currentBarLength), pb.markerIndex(pb.loopB, currentBarLength)
+	wave := pb.wave.Load()
 	for i := 0; i < currentBarLength-1; i++ {
-		if i < filledLength {
-			bar += "\x1b[34m█" // 蓝色已播放部分
+		cell := "█"
+		if wave != nil {
+			cell = string(wave.cell(i, currentBarLength-1))
+		}
+		if i == markerA {
+			bar += "\x1b[33;1mA" // 黄色的 A-B 循环标记
+		} else if i == markerB {
+			bar += "\x1b[33;1mB"
+		} else if i < filledLength {
+			bar += "\x1b[34m" + cell // 蓝色已播放部分
 		} else {
-			bar += "\x1b[30;1m█" // 深灰色未播放部分
+			bar += "\x1b[30;1m" + cell // 深灰色未播放部分
 		}
 	}
 
 	bar += fmt.Sprintf(" \x1b[0m%02d:%02d", totalMinute, totalSecond) // 重置颜色并显示总时间
+	if pb.status != "" {
+		bar += "  " + pb.status
+	}
 	return bar
 }
 
-func (pb *progressBar) printBar(wg *sync.WaitGroup, player *Player) {
+// markerIndex 返回时间点在进度条上对应的位置，未设置时返回 -1
+func (pb *progressBar) markerIndex(t time.Duration, barLength int) int {
+	if t < 0 || pb.totalTime <= 0 {
+		return -1
+	}
+	i := int(float64(t) / float64(pb.totalTime) * float64(barLength))
+	if i >= barLength-1 {
+		i = barLength - 2
+	}
+	return i
+}
+
+func (pb *progressBar) printBar(wg *sync.WaitGroup, player *Player, done chan struct{}) {
 	defer wg.Done()
 
 	ticker := time.NewTicker(time.Millisecond * 100)
@@ -73,13 +109,16 @@ func (pb *progressBar) printBar(wg *sync.WaitGroup, player *Player) {
 
 	for {
 		select {
-		case <-player.done:
+		case <-done:
 			return
 		case <-ticker.C:
 			pb.currentTime = player.getCurrentTime()
+			pb.status = player.statusText()
+			pb.loopA, pb.loopB = player.loopPoints()
 			printMu.Lock()
 			fmt.Printf("\033[12;1f")
 			fmt.Printf("\033[2K %s", pb.getCurrentBar())
+			drawPanel()
 			printMu.Unlock()
 		}
 	}
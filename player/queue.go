@@ -0,0 +1,156 @@
+package player
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+// 输出后端只初始化一次，固定采样率，各曲目重采样到这个采样率后再送进队列
+const (
+	outputSampleRate beep.SampleRate = 44100
+	resampleQuality                  = 4
+)
+
+var (
+	outputOnce sync.Once
+	outputErr  error
+)
+
+// queueEntry 是队列中的一首曲目
+type queueEntry struct {
+	streamer  beep.Streamer
+	ctrl      *beep.Ctrl
+	remaining func() int // 剩余的输出采样数，用于判断何时开始淡入淡出
+	crossfade bool       // 是否与前一首交叉淡入淡出
+}
+
+// trackQueue 是输出后端上唯一常驻的 streamer
+// 曲目按顺序排队，上一首结束后在同一次 Stream 调用里接上下一首，中间不会有空隙
+// 开启淡入淡出时，上一首的最后一段会和下一首的开头混在一起播放
+type trackQueue struct {
+	entries []*queueEntry
+	mixBuf  [][2]float64
+}
+
+var queue = &trackQueue{}
+
+func initOutput() error {
+	outputOnce.Do(func() {
+		outputErr = output.Start(outputSampleRate, outputSampleRate.N(time.Second/10), queue)
+	})
+	return outputErr
+}
+
+func (q *trackQueue) Stream(samples [][2]float64) (n int, ok bool) {
+	filled := 0
+	for filled < len(samples) {
+		if len(q.entries) == 0 {
+			// 队列为空时输出静音，保持设备一直打开
+			for i := range samples[filled:] {
+				samples[filled+i] = [2]float64{}
+			}
+			break
+		}
+		head := q.entries[0]
+		buf := samples[filled:]
+		if fade := q.fadeLength(); fade > 0 && !head.ctrl.Paused {
+			remaining := head.remaining()
+			if remaining <= fade {
+				filled += q.mix(buf, remaining, fade)
+				continue
+			}
+			// 只播放到淡出区间的起点，下一轮再开始混音
+			if remaining-fade < len(buf) {
+				buf = buf[:remaining-fade]
+			}
+		}
+		n, ok := head.streamer.Stream(buf)
+		if !ok {
+			q.entries = q.entries[1:]
+		}
+		filled += n
+	}
+	return len(samples), true
+}
+
+// fadeLength 返回队首曲目与下一首之间的淡入淡出长度（输出采样数），0 表示直接衔接
+func (q *trackQueue) fadeLength() int {
+	if len(q.entries) < 2 || !q.entries[1].crossfade {
+		return 0
+	}
+	return outputSampleRate.N(crossfade)
+}
+
+// mix 把队首曲目的结尾和下一首的开头按等功率曲线混合到 samples 中
+func (q *trackQueue) mix(samples [][2]float64, remaining, fade int) int {
+	head, next := q.entries[0], q.entries[1]
+	if len(q.mixBuf) < len(samples) {
+		q.mixBuf = make([][2]float64, len(samples))
+	}
+	incoming := q.mixBuf[:len(samples)]
+
+	hn, hok := head.streamer.Stream(samples)
+	for i := hn; i < len(samples); i++ {
+		samples[i] = [2]float64{}
+	}
+	nn, nok := next.streamer.Stream(incoming)
+	for i := nn; i < len(incoming); i++ {
+		incoming[i] = [2]float64{}
+	}
+
+	for i := range samples {
+		gainIn := 1.0
+		if i < hn {
+			t := 1 - float64(remaining-i)/float64(fade)
+			t = math.Max(0, math.Min(1, t))
+			gainIn = math.Sin(t * math.Pi / 2)
+		}
+		gainOut := math.Sqrt(1 - gainIn*gainIn)
+		for c := range samples[i] {
+			samples[i][c] = samples[i][c]*gainOut + incoming[i][c]*gainIn
+		}
+	}
+
+	if !nok {
+		q.entries = append(q.entries[:1:1], q.entries[2:]...)
+	}
+	if !hok || hn < len(samples) {
+		q.entries = q.entries[1:]
+	}
+	return len(samples)
+}
+
+func (q *trackQueue) Err() error {
+	return nil
+}
+
+// idle 判断队列是否为空，调用方需持有输出后端的锁
+func (q *trackQueue) idle() bool {
+	return len(q.entries) == 0
+}
+
+// push 把曲目接到队列末尾，必要时先启动输出后端
+func (q *trackQueue) push(entry *queueEntry) error {
+	if err := initOutput(); err != nil {
+		return err
+	}
+	output.Lock()
+	q.entries = append(q.entries, entry)
+	output.Unlock()
+	return nil
+}
+
+// remove 把曲目从队列中移除，之后输出后端不会再读取它
+func (q *trackQueue) remove(entry *queueEntry) {
+	output.Lock()
+	defer output.Unlock()
+	for i, e := range q.entries {
+		if e == entry {
+			q.entries = append(q.entries[:i:i], q.entries[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package player
+
+import (
+	"music-cli/utils"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/wav"
+)
+
+func TestMain(m *testing.M) {
+	// 配置写到临时目录，状态从默认值开始，不受本机配置影响
+	if state != nil {
+		panic("player state was loaded before the tests set the config directory")
+	}
+	dir, err := os.MkdirTemp("", "music-cli-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(utils.ConfigDirEnv, dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// useOutput 让之后的播放使用 o，并清空播放队列，测试结束时关闭 o
+func useOutput(t *testing.T, o Output) {
+	t.Helper()
+	output = o
+	outputOnce = sync.Once{}
+	outputErr = nil
+	queue = &trackQueue{}
+	t.Cleanup(func() { _ = o.Close() })
+}
+
+// writeTone 生成一个 44.1kHz 的 WAV 文件，每个采样都是 level
+func writeTone(t *testing.T, name string, d time.Duration, level [2]float64) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	format := beep.Format{SampleRate: outputSampleRate, NumChannels: 2, Precision: 2}
+	tone := beep.Take(outputSampleRate.N(d), beep.StreamerFunc(func(samples [][2]float64) (int, bool) {
+		for i := range samples {
+			samples[i] = level
+		}
+		return len(samples), true
+	}))
+	if err := wav.Encode(f, tone, format); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestPlayer(t *testing.T, path string, id int) *Player {
+	t.Helper()
+	p := NewPlayer(path, id)
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func waitDone(t *testing.T, p *Player) {
+	t.Helper()
+	select {
+	case <-p.done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("track %d did not finish", p.id)
+	}
+}
+
+func isDone(p *Player) bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestQueueAdvance(t *testing.T) {
+	// 实时模式下每首曲目结束的时间相差半秒，可以检查结束的顺序
+	useOutput(t, NewNullOutput(true))
+
+	var players []*Player
+	for i, name := range []string{"a.wav", "b.wav", "c.wav"} {
+		path := writeTone(t, name, 500*time.Millisecond, [2]float64{0.5, 0.5})
+		players = append(players, newTestPlayer(t, path, i+1))
+	}
+	for _, p := range players {
+		if err := p.enqueue(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 重复排队不会让曲目播放两次
+	if err := players[0].enqueue(false); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, p := range players {
+		waitDone(t, p)
+		for _, later := range players[i+1:] {
+			if isDone(later) {
+				t.Errorf("track %d finished before track %d", later.id, p.id)
+			}
+		}
+	}
+	output.Lock()
+	idle := queue.idle()
+	output.Unlock()
+	if !idle {
+		t.Error("queue is not empty after all tracks finished")
+	}
+}
+
+// playAndRecord 用快速 WAV 后端播放 a 和 b，返回写出的采样
+func playAndRecord(t *testing.T, fade time.Duration) [][2]float64 {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "out.wav")
+	useOutput(t, NewWAVOutput(out, false))
+	output.Lock()
+	crossfade = fade
+	output.Unlock()
+	t.Cleanup(func() { crossfade = 0 })
+
+	a := newTestPlayer(t, writeTone(t, "a.wav", 2*time.Second, [2]float64{0.5, 0}), 1)
+	b := newTestPlayer(t, writeTone(t, "b.wav", 2*time.Second, [2]float64{0, 0.5}), 2)
+	if err := a.enqueue(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.enqueue(true); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, a)
+	waitDone(t, b)
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _, err := wav.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var samples [][2]float64
+	buf := make([][2]float64, 4096)
+	for {
+		n, ok := s.Stream(buf)
+		samples = append(samples, buf[:n]...)
+		if !ok {
+			break
+		}
+	}
+	return samples
+}
+
+// countChannels 统计只有左声道、只有右声道和两边都有声音的采样数
+// 电平以第一个采样为准（beep 的 WAV 解码器把 16 位采样除以 65535，读出来的幅度只有一半）
+func countChannels(samples [][2]float64) (left, right, both int) {
+	audible := samples[0][0] / 100
+	for _, s := range samples {
+		l, r := s[0] > audible, s[1] > audible
+		switch {
+		case l && r:
+			both++
+		case l:
+			left++
+		case r:
+			right++
+		}
+	}
+	return left, right, both
+}
+
+// audibleSpan 返回第一个和最后一个有声音的采样之间的长度
+func audibleSpan(samples [][2]float64) int {
+	audible := samples[0][0] / 100
+	first, last := -1, -1
+	for i, s := range samples {
+		if s[0] > audible || s[1] > audible {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	return last - first + 1
+}
+
+func within(got, want, tolerance int) bool {
+	return got >= want-tolerance && got <= want+tolerance
+}
+
+func TestQueueGapless(t *testing.T) {
+	samples := playAndRecord(t, 0)
+	left, right, both := countChannels(samples)
+	track := outputSampleRate.N(2 * time.Second)
+	// 两首曲目首尾相接，中间没有静音也没有重叠
+	if !within(left, track, 64) || !within(right, track, 64) || both > 64 {
+		t.Errorf("left=%d right=%d both=%d, want %d, %d, 0", left, right, both, track, track)
+	}
+	if span := audibleSpan(samples); !within(span, 2*track, 64) {
+		t.Errorf("tracks span %d samples, want %d", span, 2*track)
+	}
+}
+
+func TestQueueCrossfade(t *testing.T) {
+	fade := 500 * time.Millisecond
+	samples := playAndRecord(t, fade)
+	_, _, both := countChannels(samples)
+	overlap := outputSampleRate.N(fade)
+	track := outputSampleRate.N(2 * time.Second)
+	// 淡入淡出的两端低于判断门限，重叠的采样数比淡入淡出时长略短；
+	// 剩余时长按解码位置估计，重采样器会预读一小段，淡出比曲目真正结束早十几毫秒
+	if !within(both, overlap, 1024) {
+		t.Errorf("tracks overlap for %d samples, want about %d", both, overlap)
+	}
+	if span := audibleSpan(samples); !within(span, 2*track-overlap, 1024) {
+		t.Errorf("tracks span %d samples, want %d", span, 2*track-overlap)
+	}
+	// 等功率曲线：交叉区间的总功率保持不变
+	level := samples[0][0]
+	for i, s := range samples {
+		if s[0] > level/100 && s[1] > level/100 {
+			if power := (s[0]*s[0] + s[1]*s[1]) / (level * level); power < 0.9 || power > 1.1 {
+				t.Fatalf("sample %d has %.2f times the power during crossfade", i, power)
+			}
+		}
+	}
+}
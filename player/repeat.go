@@ -0,0 +1,40 @@
+package player
+
+import "sync/atomic"
+
+// 循环模式
+const (
+	repeatAll int32 = iota // 列表循环
+	repeatOne              // 单曲循环
+	repeatOff              // 播完列表后返回目录
+)
+
+var repeatMode atomic.Int32
+
+func cycleRepeatMode() {
+	repeatMode.Store((repeatMode.Load() + 1) % 3)
+}
+
+// nextIndex 返回当前曲目自然播完后要播放的曲目，-1 表示列表已经播完
+func nextIndex(current, total int) int {
+	switch repeatMode.Load() {
+	case repeatOne:
+		return current
+	case repeatOff:
+		if current+1 >= total {
+			return -1
+		}
+		return current + 1
+	}
+	return (current + 1) % total
+}
+
+func repeatText() string {
+	switch repeatMode.Load() {
+	case repeatOne:
+		return "单曲循环"
+	case repeatOff:
+		return "顺序播放"
+	}
+	return "列表循环"
+}
@@ -0,0 +1,187 @@
+package player
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dhowden/tag"
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
+)
+
+// ReplayGain 模式
+const (
+	replayGainOff   = "off"
+	replayGainTrack = "track"
+	replayGainAlbum = "album"
+	replayGainAuto  = "auto" // 按目录顺序播放时用专辑增益，随机播放时用单曲增益
+)
+
+var replayGainModes = []string{replayGainOff, replayGainTrack, replayGainAlbum, replayGainAuto}
+
+// replayGain 是从标签中读出的 ReplayGain 信息，增益单位为 dB，峰值为线性幅度
+type replayGain struct {
+	trackGain, albumGain float64
+	trackPeak, albumPeak float64
+	hasTrack, hasAlbum   bool
+}
+
+// readReplayGain 从 Vorbis 注释或 ID3v2 的 TXXX 帧中读取 ReplayGain 信息
+func readReplayGain(meta tag.Metadata) replayGain {
+	var rg replayGain
+	if meta == nil {
+		return rg
+	}
+	for key, value := range meta.Raw() {
+		var name, text string
+		switch v := value.(type) {
+		case string:
+			// Vorbis 注释的键已经被转换成小写
+			name, text = key, v
+		case *tag.Comm:
+			// TXXX 帧的名字存放在描述里，大小写不统一
+			if !strings.HasPrefix(key, "TXXX") && !strings.HasPrefix(key, "TXX") {
+				continue
+			}
+			name, text = strings.ToLower(v.Description), v.Text
+		default:
+			continue
+		}
+		switch name {
+		case "replaygain_track_gain":
+			rg.trackGain, rg.hasTrack = parseGain(text)
+		case "replaygain_album_gain":
+			rg.albumGain, rg.hasAlbum = parseGain(text)
+		case "replaygain_track_peak":
+			rg.trackPeak, _ = parsePeak(text)
+		case "replaygain_album_peak":
+			rg.albumPeak, _ = parsePeak(text)
+		}
+	}
+	return rg
+}
+
+// parseGain 解析形如 "-6.20 dB" 的增益值
+func parseGain(text string) (float64, bool) {
+	text = strings.TrimSpace(text)
+	if len(text) >= 2 && strings.EqualFold(text[len(text)-2:], "db") {
+		text = strings.TrimSpace(text[:len(text)-2])
+	}
+	gain, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, false
+	}
+	return gain, true
+}
+
+func parsePeak(text string) (float64, bool) {
+	peak, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || peak <= 0 {
+		return 0, false
+	}
+	return peak, true
+}
+
+// factor 返回指定模式下的线性增益，并根据峰值限制增益防止削波
+func (rg replayGain) factor(mode string, sequential bool) float64 {
+	if mode == replayGainAuto {
+		mode = replayGainTrack
+		if sequential {
+			mode = replayGainAlbum
+		}
+	}
+
+	var gain, peak float64
+	switch {
+	case mode == replayGainAlbum && rg.hasAlbum:
+		gain, peak = rg.albumGain, rg.albumPeak
+	case mode == replayGainAlbum && rg.hasTrack, mode == replayGainTrack && rg.hasTrack:
+		// 没有专辑增益时退回单曲增益
+		gain, peak = rg.trackGain, rg.trackPeak
+	case mode == replayGainTrack && rg.hasAlbum:
+		gain, peak = rg.albumGain, rg.albumPeak
+	default:
+		return 1
+	}
+
+	factor := math.Pow(10, gain/20)
+	if peak > 0 && factor*peak > 1 {
+		factor = 1 / peak
+	}
+	return factor
+}
+
+func isReplayGainMode(mode string) bool {
+	for _, m := range replayGainModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func currentReplayGainMode() string {
+	lockState()
+	defer stateMu.Unlock()
+	for _, mode := range replayGainModes {
+		if mode == state.ReplayGain {
+			return mode
+		}
+	}
+	return replayGainOff
+}
+
+// newReplayGain 按当前模式创建增益阶段
+func (p *Player) newReplayGain(s beep.Streamer) *effects.Gain {
+	return &effects.Gain{
+		Streamer: s,
+		Gain:     p.rg.factor(currentReplayGainMode(), p.sequential) - 1,
+	}
+}
+
+// cycleReplayGain 切换到下一个 ReplayGain 模式并应用到正在播放和已排队的曲目
+func cycleReplayGain(players ...*Player) {
+	mode := currentReplayGainMode()
+	lockState()
+	for i, m := range replayGainModes {
+		if m == mode {
+			state.ReplayGain = replayGainModes[(i+1)%len(replayGainModes)]
+			break
+		}
+	}
+	_ = saveState()
+	stateMu.Unlock()
+
+	for _, p := range players {
+		p.applyReplayGain()
+	}
+}
+
+func (p *Player) applyReplayGain() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.gain == nil {
+		return
+	}
+	gain := p.rg.factor(currentReplayGainMode(), p.sequential) - 1
+	output.Lock()
+	p.gain.Gain = gain
+	output.Unlock()
+}
+
+func replayGainText() string {
+	switch currentReplayGainMode() {
+	case replayGainTrack:
+		return "RG 单曲"
+	case replayGainAlbum:
+		return "RG 专辑"
+	case replayGainAuto:
+		return "RG 自动"
+	}
+	return ""
+}
@@ -0,0 +1,220 @@
+package player
+
+import (
+	"encoding/json"
+	"fmt"
+	"music-cli/utils"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	sessionFileName   = "session.json"
+	positionsFileName = "positions.json"
+
+	// 超过这个时长的文件（混音、有声书等）单独记住播放位置，下次打开时接着播
+	longTrackDuration = 20 * time.Minute
+	// 离开头或结尾太近的位置不值得记住
+	minResumePosition = 30 * time.Second
+	maxSavedPositions = 200
+)
+
+// session 是退出播放时记录的播放列表和位置，主界面可以从这里继续
+type session struct {
+	Root       string         `json:"root"`
+	Page       int            `json:"page"`
+	Queue      []sessionEntry `json:"queue"`
+	Index      int            `json:"index"`
+	Position   time.Duration  `json:"position"`
+	Sequential bool           `json:"sequential"`
+}
+
+type sessionEntry struct {
+	Path  string `json:"path"`
+	Title string `json:"title,omitempty"` // 播放列表中的标题
+}
+
+// savedPosition 是长文件上次停下的位置
+type savedPosition struct {
+	Position time.Duration `json:"position"`
+	Updated  time.Time     `json:"updated"`
+}
+
+var positionsMu sync.Mutex
+
+func configFilePath(name string) (string, error) {
+	dir, err := utils.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func readJSON(name string, v any) error {
+	path, err := configFilePath(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(name string, v any) error {
+	path, err := configFilePath(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// saveSession 记录当前的播放列表，下次从第 index 首的 position 处继续
+func saveSession(root string, page int, plist []*Player, index int, position time.Duration) {
+	s := session{Root: root, Page: page, Index: index, Position: position, Sequential: plist[index].sequential}
+	for _, p := range plist {
+		s.Queue = append(s.Queue, sessionEntry{Path: p.path, Title: p.title})
+	}
+	_ = writeJSON(sessionFileName, &s)
+}
+
+// clearSession 播放列表正常播完后没有需要继续的内容
+func clearSession() {
+	if path, err := configFilePath(sessionFileName); err == nil {
+		_ = os.Remove(path)
+	}
+}
+
+// loadSession 读取上次的播放记录，已经不存在的曲目会被去掉
+func loadSession() (*session, bool) {
+	var s session
+	if err := readJSON(sessionFileName, &s); err != nil || len(s.Queue) == 0 {
+		return nil, false
+	}
+	if s.Index < 0 || s.Index >= len(s.Queue) {
+		s.Index, s.Position = 0, 0
+	}
+	var queue []sessionEntry
+	index := 0
+	for i, entry := range s.Queue {
+		if !trackExists(entry.Path) {
+			if i == s.Index {
+				s.Position = 0
+			}
+			continue
+		}
+		if i <= s.Index {
+			index = len(queue)
+		}
+		queue = append(queue, entry)
+	}
+	if len(queue) == 0 {
+		return nil, false
+	}
+	s.Queue, s.Index = queue, index
+	return &s, true
+}
+
+func trackExists(path string) bool {
+	if _, _, ok := utils.SplitCueTrackPath(path); ok {
+		_, err := utils.LoadCueTrack(path)
+		return err == nil
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// players 按记录重建 Player 列表，当前曲目从记录的位置开始播放
+func (s *session) players() []*Player {
+	players := make([]*Player, len(s.Queue))
+	for i, entry := range s.Queue {
+		p := NewPlayer(entry.Path, i+1)
+		p.title = entry.Title
+		p.sequential = s.Sequential
+		players[i] = p
+	}
+	players[s.Index].resumeAt = s.Position
+	return players
+}
+
+// describe 返回主界面上显示的上次播放信息
+func (s *session) describe() string {
+	entry := s.Queue[s.Index]
+	name := entry.Title
+	if name == "" {
+		name = utils.TrackName(entry.Path)
+	}
+	seconds := int(s.Position.Seconds())
+	return fmt.Sprintf("%s %02d:%02d（第 %d / %d 首）", name, seconds/60, seconds%60, s.Index+1, len(s.Queue))
+}
+
+func loadPositions() map[string]savedPosition {
+	positions := make(map[string]savedPosition)
+	_ = readJSON(positionsFileName, &positions)
+	return positions
+}
+
+// rememberPosition 长文件停止播放时记住位置，快播完时忘掉位置
+func rememberPosition(p *Player) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	if p.streamer == nil {
+		p.mu.Unlock()
+		return
+	}
+	total := p.format.SampleRate.D(p.streamer.Len())
+	p.mu.Unlock()
+	if total < longTrackDuration {
+		return
+	}
+	position := p.getCurrentTime()
+	if position < minResumePosition || total-position < minResumePosition {
+		forgetPosition(p.path)
+		return
+	}
+
+	positionsMu.Lock()
+	defer positionsMu.Unlock()
+	positions := loadPositions()
+	positions[p.path] = savedPosition{Position: position, Updated: time.Now()}
+	// 只保留最近的一部分记录
+	for len(positions) > maxSavedPositions {
+		oldest := ""
+		for path, saved := range positions {
+			if oldest == "" || saved.Updated.Before(positions[oldest].Updated) {
+				oldest = path
+			}
+		}
+		delete(positions, oldest)
+	}
+	_ = writeJSON(positionsFileName, positions)
+}
+
+// forgetPosition 长文件播完后不再从中间继续
+func forgetPosition(path string) {
+	positionsMu.Lock()
+	defer positionsMu.Unlock()
+	positions := loadPositions()
+	if _, ok := positions[path]; !ok {
+		return
+	}
+	delete(positions, path)
+	_ = writeJSON(positionsFileName, positions)
+}
+
+// savedPositionOf 返回长文件上次停下的位置
+func savedPositionOf(path string) (time.Duration, bool) {
+	positionsMu.Lock()
+	defer positionsMu.Unlock()
+	saved, ok := loadPositions()[path]
+	return saved.Position, ok
+}
@@ -0,0 +1,218 @@
+package player
+
+import (
+	"time"
+
+	"github.com/faiface/beep"
+)
+
+const (
+	// 低于 -60dBFS 的采样当作静音
+	silenceThreshold = 0.001
+	// 中间的静音超过这个长度才跳过，短的停顿是音乐的一部分
+	silenceMinGap = 2 * time.Second
+	// 跳过中间的静音时保留一小段，前后两段不会硬接在一起
+	silenceKeep = 500 * time.Millisecond
+	// 每次从解码器读取的采样数
+	silenceChunk = 4096
+)
+
+// silenceSkipper 紧贴在解码器之上，打开“跳过静音”时去掉开头、结尾和中间较长的静音
+// 队首是静音时向后预读最多 silenceMinGap，确定静音有多长再决定输出还是跳过
+// 跳过的静音照常从解码器读出，Position 减去预读但还没输出的采样，
+// 保留的一小段播放期间再减去它后面被丢掉的静音，进度条和歌词仍然准确
+type silenceSkipper struct {
+	beep.StreamSeekCloser
+	enabled bool
+	minGap  int // 采样数
+	keep    int
+
+	buf     [][2]float64
+	pending [][2]float64 // 已经从解码器读出、还没有输出的采样
+	pass    int          // 队首可以直接输出的静音采样数
+	dropped int          // 中间静音保留的一段（就是 pass）后面被丢掉的采样数，输出完这一段后清零
+	started bool         // 已经输出过声音，之后的静音不再算作开头
+	chunk   [][2]float64
+}
+
+func newSilenceSkipper(s beep.StreamSeekCloser, sr beep.SampleRate) *silenceSkipper {
+	lockState()
+	enabled := state.SkipSilence
+	stateMu.Unlock()
+	minGap := sr.N(silenceMinGap)
+	buf := make([][2]float64, 0, minGap+silenceChunk)
+	return &silenceSkipper{
+		StreamSeekCloser: s,
+		enabled:          enabled,
+		minGap:           minGap,
+		keep:             sr.N(silenceKeep),
+		buf:              buf,
+		pending:          buf,
+		chunk:            make([][2]float64, silenceChunk),
+	}
+}
+
+func isSilent(sample [2]float64) bool {
+	return sample[0] < silenceThreshold && sample[0] > -silenceThreshold &&
+		sample[1] < silenceThreshold && sample[1] > -silenceThreshold
+}
+
+// fill 从解码器再读一块追加到 pending，解码器已经结束时返回 false
+func (s *silenceSkipper) fill() bool {
+	n, _ := s.StreamSeekCloser.Stream(s.chunk)
+	s.pending = append(s.pending, s.chunk[:n]...)
+	return n > 0
+}
+
+// silentRun 返回队首连续静音的长度，预读到静音结束、达到 minGap 或解码器结束为止
+// end 表示静音一直持续到曲目结尾
+func (s *silenceSkipper) silentRun() (run int, end bool) {
+	for {
+		for run < len(s.pending) && isSilent(s.pending[run]) {
+			run++
+		}
+		if run < len(s.pending) || run >= s.minGap {
+			return run, false
+		}
+		if !s.fill() {
+			return run, true
+		}
+	}
+}
+
+// skipGap 丢掉解码器中剩下的静音，直到遇到声音，静音到结尾时返回 false
+func (s *silenceSkipper) skipGap() bool {
+	for {
+		n, _ := s.StreamSeekCloser.Stream(s.chunk)
+		if n == 0 {
+			return false
+		}
+		for i, sample := range s.chunk[:n] {
+			if !isSilent(sample) {
+				s.pending = append(s.pending, s.chunk[i:n]...)
+				return true
+			}
+		}
+	}
+}
+
+func (s *silenceSkipper) Stream(samples [][2]float64) (n int, ok bool) {
+	if !s.enabled && len(s.pending) == 0 {
+		n, ok = s.StreamSeekCloser.Stream(samples)
+		s.started = s.started || n > 0
+		return n, ok
+	}
+	for n < len(samples) {
+		if len(s.pending) == 0 {
+			s.pending = s.buf[:0]
+			if !s.fill() {
+				break
+			}
+		}
+
+		// 关闭时把预读的采样原样输出；否则输出到下一段需要判断的静音之前
+		count := len(s.pending)
+		if s.enabled {
+			if s.pass > 0 {
+				count = min(count, s.pass)
+			} else {
+				count = 0
+				for count < len(s.pending) && !isSilent(s.pending[count]) {
+					count++
+				}
+			}
+		}
+		if count > 0 {
+			count = copy(samples[n:], s.pending[:count])
+			s.pending = s.pending[count:]
+			s.pass = max(0, s.pass-count)
+			if s.pass == 0 {
+				s.dropped = 0
+			}
+			s.started = true
+			n += count
+			continue
+		}
+
+		run, end := s.silentRun()
+		switch {
+		case end:
+			// 结尾的静音全部丢掉
+			s.pending = s.pending[:0]
+			return n, n > 0
+		case run < s.minGap && s.started:
+			// 短暂的停顿照常输出
+			s.pass = run
+		default:
+			// 开头的静音全部跳过，中间较长的静音只保留一小段
+			keep := 0
+			if s.started {
+				keep = s.keep
+			}
+			head := s.Position()
+			rest := s.pending[run:]
+			s.pending = append(s.pending[:keep], rest...)
+			if len(rest) == 0 && !s.skipGap() {
+				s.pending = s.pending[:0]
+				return n, n > 0
+			}
+			s.pass = keep
+			if keep > 0 {
+				// 队首仍是静音开始的位置，它和解码器之间多了被丢掉的部分
+				s.dropped = s.StreamSeekCloser.Position() - len(s.pending) - head
+			}
+		}
+	}
+	return n, n > 0
+}
+
+// Position 返回下一个输出的采样在文件中的位置，不包括预读和还没播到的被丢掉的部分
+func (s *silenceSkipper) Position() int {
+	return s.StreamSeekCloser.Position() - len(s.pending) - s.dropped
+}
+
+// Seek 跳转时丢掉预读的采样，跳到开头时重新跳过开头的静音
+func (s *silenceSkipper) Seek(p int) error {
+	s.pending = s.buf[:0]
+	s.pass = 0
+	s.dropped = 0
+	s.started = p > 0
+	return s.StreamSeekCloser.Seek(p)
+}
+
+// toggleSkipSilence 切换跳过静音，保存到状态文件并应用到正在播放和已排队的曲目
+func toggleSkipSilence(players ...*Player) {
+	lockState()
+	state.SkipSilence = !state.SkipSilence
+	enabled := state.SkipSilence
+	_ = saveState()
+	stateMu.Unlock()
+
+	for _, p := range players {
+		p.applySkipSilence(enabled)
+	}
+}
+
+func (p *Player) applySkipSilence(enabled bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.skip == nil {
+		return
+	}
+	output.Lock()
+	p.skip.enabled = enabled
+	output.Unlock()
+}
+
+func skipSilenceText() string {
+	lockState()
+	defer stateMu.Unlock()
+	if state.SkipSilence {
+		return "跳过静音"
+	}
+	return ""
+}
@@ -0,0 +1,145 @@
+package player
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/faiface/beep"
+)
+
+// sliceSeeker 是内存中的 StreamSeekCloser
+type sliceSeeker struct {
+	samples [][2]float64
+	pos     int
+}
+
+func (s *sliceSeeker) Stream(samples [][2]float64) (int, bool) {
+	n := copy(samples, s.samples[s.pos:])
+	s.pos += n
+	return n, n > 0
+}
+
+func (s *sliceSeeker) Err() error    { return nil }
+func (s *sliceSeeker) Len() int      { return len(s.samples) }
+func (s *sliceSeeker) Position() int { return s.pos }
+func (s *sliceSeeker) Close() error  { return nil }
+
+func (s *sliceSeeker) Seek(p int) error {
+	if p < 0 || p > len(s.samples) {
+		return fmt.Errorf("seek %d out of range", p)
+	}
+	s.pos = p
+	return nil
+}
+
+// span 是一段有声音或静音的采样
+type span struct {
+	n     int
+	sound bool
+}
+
+// spans 拼出测试信号，有声音的采样值由它在文件中的位置算出（见 sampleIndex），方便检查输出的是哪个采样
+func spans(parts ...span) [][2]float64 {
+	var samples [][2]float64
+	for _, p := range parts {
+		for range p.n {
+			v := 0.0
+			if p.sound {
+				v = 0.01 + float64(len(samples))/1e6
+			}
+			samples = append(samples, [2]float64{v, v})
+		}
+	}
+	return samples
+}
+
+func sampleIndex(sample [2]float64) int {
+	return int((sample[0]-0.01)*1e6 + 0.5)
+}
+
+// ranges 返回 [from, to) 的各段位置
+func ranges(r ...[2]int) []int {
+	var out []int
+	for _, x := range r {
+		for i := x[0]; i < x[1]; i++ {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func TestSilenceSkipperPosition(t *testing.T) {
+	// 1000Hz 时 minGap 是 2000 个采样，中间保留 500 个
+	const sr = beep.SampleRate(1000)
+	tests := []struct {
+		name    string
+		signal  [][2]float64
+		enabled bool
+		want    []int // 依次输出的采样在文件中的位置
+	}{
+		{
+			name:    "disabled",
+			signal:  spans(span{100, false}, span{1000, true}, span{5000, false}, span{1000, true}),
+			enabled: false,
+			want:    ranges([2]int{0, 7100}),
+		},
+		{
+			name:    "leading and trailing silence",
+			signal:  spans(span{3000, false}, span{1000, true}, span{3000, false}),
+			enabled: true,
+			want:    ranges([2]int{3000, 4000}),
+		},
+		{
+			name:    "short pause is kept",
+			signal:  spans(span{1000, true}, span{1500, false}, span{1000, true}),
+			enabled: true,
+			want:    ranges([2]int{0, 3500}),
+		},
+		{
+			name:    "long gap within one read",
+			signal:  spans(span{1000, true}, span{2500, false}, span{1000, true}),
+			enabled: true,
+			want:    ranges([2]int{0, 1500}, [2]int{3500, 4500}),
+		},
+		{
+			// 静音比预读长，剩下的部分由 skipGap 丢掉
+			name:    "long gap across reads",
+			signal:  spans(span{1000, true}, span{12000, false}, span{1000, true}),
+			enabled: true,
+			want:    ranges([2]int{0, 1500}, [2]int{13000, 14000}),
+		},
+		{
+			name:    "two gaps",
+			signal:  spans(span{1000, true}, span{3000, false}, span{700, true}, span{9000, false}, span{300, true}),
+			enabled: true,
+			want:    ranges([2]int{0, 1500}, [2]int{4000, 5200}, [2]int{13700, 14000}),
+		},
+	}
+	for _, tt := range tests {
+		for _, chunk := range []int{1, 333, 4096} {
+			s := newSilenceSkipper(&sliceSeeker{samples: tt.signal}, sr)
+			s.enabled = tt.enabled
+			buf := make([][2]float64, chunk)
+			// 每次读完后 Position 应该是下一个要输出的采样在文件中的位置
+			k := 0
+			for {
+				n, ok := s.Stream(buf)
+				for i, sample := range buf[:n] {
+					if from := sampleIndex(sample); sample[0] != 0 && k+i < len(tt.want) && from != tt.want[k+i] {
+						t.Fatalf("%s (chunk %d): output sample %d is from %d, want %d", tt.name, chunk, k+i, from, tt.want[k+i])
+					}
+				}
+				k += n
+				if k < len(tt.want) && s.Position() != tt.want[k] {
+					t.Fatalf("%s (chunk %d): position %d after %d samples, want %d", tt.name, chunk, s.Position(), k, tt.want[k])
+				}
+				if !ok {
+					break
+				}
+			}
+			if k != len(tt.want) {
+				t.Errorf("%s (chunk %d): output %d samples, want %d", tt.name, chunk, k, len(tt.want))
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package player
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// 睡眠定时的方式
+const (
+	sleepOff        = iota
+	sleepMinutes    // 指定分钟数后
+	sleepEndOfTrack // 当前曲目播完后
+	sleepTracks     // 播完 N 首后
+)
+
+const (
+	sleepFadeDuration = 5 * time.Second // 到时前的淡出时长
+	sleepFadeFloor    = -60.0           // 淡出结束时的衰减（dB）
+	sleepCheckPeriod  = 200 * time.Millisecond
+)
+
+var (
+	sleepMu         sync.Mutex
+	sleepMode       int
+	sleepDeadline   time.Time // sleepMinutes 的到时时间
+	sleepTracksLeft int       // 还要播完几首，sleepEndOfTrack 时为 1
+	sleepFadeDB     float64   // 淡出时叠加在音量上的衰减（dB）
+)
+
+// startSleepTimer 开始睡眠定时，n 是分钟数或曲目数
+func startSleepTimer(mode, n int) {
+	sleepMu.Lock()
+	defer sleepMu.Unlock()
+	sleepMode = mode
+	sleepFadeDB = 0
+	switch mode {
+	case sleepMinutes:
+		sleepDeadline = time.Now().Add(time.Duration(n) * time.Minute)
+	case sleepEndOfTrack:
+		sleepTracksLeft = 1
+	case sleepTracks:
+		sleepTracksLeft = n
+	}
+}
+
+// cancelSleepTimer 取消定时并恢复音量
+func cancelSleepTimer(players ...*Player) {
+	sleepMu.Lock()
+	sleepMode = sleepOff
+	sleepFadeDB = 0
+	sleepMu.Unlock()
+	for _, p := range players {
+		p.applyVolume()
+	}
+}
+
+// sleepFade 返回当前淡出的衰减（dB）
+func sleepFade() float64 {
+	sleepMu.Lock()
+	defer sleepMu.Unlock()
+	return sleepFadeDB
+}
+
+// sleepTimeLeft 返回距离到时还有多久，按曲目计数且不止剩一首时无法估计，返回 false
+func sleepTimeLeft(current *Player) (time.Duration, bool) {
+	sleepMu.Lock()
+	mode, deadline, tracks := sleepMode, sleepDeadline, sleepTracksLeft
+	sleepMu.Unlock()
+
+	switch mode {
+	case sleepMinutes:
+		return time.Until(deadline), true
+	case sleepEndOfTrack, sleepTracks:
+		if tracks == 1 {
+			return current.remainingTime()
+		}
+	}
+	return 0, false
+}
+
+// checkSleepTimer 在播放界面中定期调用，更新淡出音量，到时返回 true
+func checkSleepTimer(current *Player, players ...*Player) bool {
+	left, ok := sleepTimeLeft(current)
+	if !ok {
+		return false
+	}
+	if left <= 0 {
+		return true
+	}
+	if left < sleepFadeDuration {
+		sleepMu.Lock()
+		sleepFadeDB = sleepFadeFloor * (1 - float64(left)/float64(sleepFadeDuration))
+		sleepMu.Unlock()
+		for _, p := range players {
+			p.applyVolume()
+		}
+	}
+	return false
+}
+
+// sleepTrackFinished 在一首曲目自然播完时调用，按曲目计数的定时到时返回 true
+func sleepTrackFinished() bool {
+	sleepMu.Lock()
+	defer sleepMu.Unlock()
+	if sleepMode != sleepEndOfTrack && sleepMode != sleepTracks {
+		return false
+	}
+	sleepTracksLeft--
+	return sleepTracksLeft <= 0
+}
+
+// sleepExitsProgram 返回到时后是否直接退出程序，否则返回目录
+func sleepExitsProgram() bool {
+	lockState()
+	defer stateMu.Unlock()
+	return state.SleepExit
+}
+
+func setSleepExitsProgram(exit bool) {
+	lockState()
+	defer stateMu.Unlock()
+	state.SleepExit = exit
+	_ = saveState()
+}
+
+// remainingTime 返回当前曲目按播放速度计算的剩余时间，A-B 循环时曲目不会结束，返回 false
+func (p *Player) remainingTime() (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.streamer == nil || p.ab == nil {
+		return 0, false
+	}
+	output.Lock()
+	defer output.Unlock()
+	if p.ab.active() {
+		return 0, false
+	}
+	left := p.format.SampleRate.D(p.streamer.Len() - p.streamer.Position())
+	return time.Duration(float64(left) / playbackSpeed), true
+}
+
+func sleepText(current *Player) string {
+	sleepMu.Lock()
+	mode, tracks := sleepMode, sleepTracksLeft
+	sleepMu.Unlock()
+
+	switch mode {
+	case sleepMinutes:
+		left, _ := sleepTimeLeft(current)
+		seconds := int(math.Ceil(math.Max(0, left.Seconds())))
+		return fmt.Sprintf("睡眠 %02d:%02d", seconds/60, seconds%60)
+	case sleepEndOfTrack, sleepTracks:
+		if tracks == 1 {
+			return "睡眠 本曲后"
+		}
+		return fmt.Sprintf("睡眠 %d 首后", tracks)
+	}
+	return ""
+}
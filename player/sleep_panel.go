@@ -0,0 +1,122 @@
+package player
+
+import "fmt"
+
+// 面板中可以选择的分钟数和曲目数范围
+const (
+	sleepMinuteStep = 5
+	sleepMaxMinutes = 180
+	sleepMaxTracks  = 50
+)
+
+var sleepModeNames = []string{"关闭", "定时", "本曲结束后", "播放 N 首后"}
+
+// 上次在面板中选择的值，下次打开面板时沿用
+var (
+	lastSleepMinutes = 30
+	lastSleepTracks  = 3
+)
+
+// sleepPanel 是播放界面中的睡眠定时面板
+// ↑/↓ 选择项目，←/→ 修改，回车开始（选择“关闭”时取消定时），t 或 q 关闭面板
+type sleepPanel struct {
+	row     int // 0 方式，1 分钟数或曲目数，2 到时后的动作
+	mode    int
+	minutes int
+	tracks  int
+	exit    bool
+}
+
+func newSleepPanel() *sleepPanel {
+	sleepMu.Lock()
+	mode := sleepMode
+	sleepMu.Unlock()
+	if mode == sleepOff {
+		mode = sleepMinutes
+	}
+	return &sleepPanel{
+		mode:    mode,
+		minutes: lastSleepMinutes,
+		tracks:  lastSleepTracks,
+		exit:    sleepExitsProgram(),
+	}
+}
+
+func (p *sleepPanel) handleKey(k key, players []*Player) bool {
+	switch k {
+	case 't', 'T', 'q', 'Q':
+		return false
+	case keyUp, 'k':
+		p.row = (p.row + 2) % 3
+	case keyDown, 'j':
+		p.row = (p.row + 1) % 3
+	case keyLeft, 'h':
+		p.adjust(-1)
+	case keyRight, 'l':
+		p.adjust(1)
+	case keyEnter, '\n':
+		setSleepExitsProgram(p.exit)
+		switch p.mode {
+		case sleepOff:
+			cancelSleepTimer(players...)
+		case sleepMinutes:
+			lastSleepMinutes = p.minutes
+			startSleepTimer(sleepMinutes, p.minutes)
+		case sleepTracks:
+			lastSleepTracks = p.tracks
+			startSleepTimer(sleepTracks, p.tracks)
+		default:
+			startSleepTimer(p.mode, 1)
+		}
+		// 重新开始定时时恢复可能已经在淡出的音量
+		for _, player := range players {
+			player.applyVolume()
+		}
+		return false
+	}
+	return true
+}
+
+func (p *sleepPanel) adjust(delta int) {
+	switch p.row {
+	case 0:
+		p.mode = (p.mode + delta + len(sleepModeNames)) % len(sleepModeNames)
+	case 1:
+		switch p.mode {
+		case sleepMinutes:
+			p.minutes = max(sleepMinuteStep, min(sleepMaxMinutes, p.minutes+delta*sleepMinuteStep))
+		case sleepTracks:
+			p.tracks = max(1, min(sleepMaxTracks, p.tracks+delta))
+		}
+	case 2:
+		p.exit = !p.exit
+	}
+}
+
+func (p *sleepPanel) lines() []string {
+	value := "-"
+	switch p.mode {
+	case sleepMinutes:
+		value = fmt.Sprintf("%d 分钟", p.minutes)
+	case sleepTracks:
+		value = fmt.Sprintf("%d 首", p.tracks)
+	}
+	action := "返回目录"
+	if p.exit {
+		action = "退出程序"
+	}
+	items := []string{
+		"方式:   " + sleepModeNames[p.mode],
+		"时长:   " + value,
+		"到时后: " + action,
+	}
+	lines := []string{"  \x1b[1m睡眠定时\x1b[0m"}
+	for i, item := range items {
+		marker := "  "
+		if i == p.row {
+			marker = "\x1b[34m➣ "
+		}
+		lines = append(lines, "  "+marker+"◀ "+item+" ▶\x1b[0m")
+	}
+	return append(lines, "  ↑/↓ 选择  ←/→ 修改  回车确定  t 关闭")
+}
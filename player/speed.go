@@ -0,0 +1,67 @@
+package player
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/faiface/beep"
+)
+
+const (
+	speedStep = 0.1
+	minSpeed  = 0.5
+	maxSpeed  = 2.0
+)
+
+// playbackSpeed 是本次运行的播放速度倍率，只在输出后端的锁内读写
+// 进度条和歌词读取的是解码器位置，始终是媒体时间，不受倍率影响
+var playbackSpeed = 1.0
+
+// newSpeed 按当前倍率创建变速阶段
+func newSpeed(s beep.Streamer) *beep.Resampler {
+	output.Lock()
+	defer output.Unlock()
+	return beep.ResampleRatio(resampleQuality, playbackSpeed, s)
+}
+
+// changeSpeed 调整播放速度并立即应用到正在播放和已排队的曲目，delta 为 0 时恢复原速
+func changeSpeed(delta float64, players ...*Player) {
+	output.Lock()
+	if delta == 0 {
+		playbackSpeed = 1
+	} else {
+		// 按步长取整，避免浮点累加误差
+		playbackSpeed = math.Round((playbackSpeed+delta)/speedStep) * speedStep
+		playbackSpeed = math.Max(minSpeed, math.Min(maxSpeed, playbackSpeed))
+	}
+	output.Unlock()
+
+	for _, p := range players {
+		p.applySpeed()
+	}
+}
+
+func (p *Player) applySpeed() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.speed == nil {
+		return
+	}
+	output.Lock()
+	p.speed.SetRatio(playbackSpeed)
+	output.Unlock()
+}
+
+func speedText() string {
+	output.Lock()
+	speed := playbackSpeed
+	output.Unlock()
+	if speed == 1 {
+		return ""
+	}
+	return fmt.Sprintf("速度 %.1fx", speed)
+}
@@ -0,0 +1,68 @@
+package player
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+const stateFileName = "state.json"
+
+// playerState 是需要跨次启动保存的播放器状态
+type playerState struct {
+	Volume      float64         `json:"volume"` // 音量，单位 dB
+	Muted       bool            `json:"muted"`
+	ReplayGain  string          `json:"replay_gain"` // off / track / album / auto
+	EQ          eqPreset        `json:"eq"`
+	Channels    channelSettings `json:"channels"`     // 平衡、单声道和左右互换
+	SkipSilence bool            `json:"skip_silence"` // 跳过开头、结尾和中间较长的静音
+	Visualizer  string          `json:"visualizer"`   // off / spectrum / meter / both
+	SleepExit   bool            `json:"sleep_exit"`   // 睡眠定时到时后退出程序，否则返回目录
+}
+
+var (
+	stateMu sync.Mutex
+	state   *playerState // 第一次 lockState 时才读取，之前不碰配置目录
+)
+
+// lockState 锁住 stateMu，需要时先读取状态文件，用完后调用 stateMu.Unlock
+func lockState() {
+	stateMu.Lock()
+	if state == nil {
+		state = loadState()
+	}
+}
+
+func stateFilePath() (string, error) {
+	return configFilePath(stateFileName)
+}
+
+// loadState 读取上次保存的状态，文件不存在或损坏时使用默认值
+func loadState() *playerState {
+	s := &playerState{}
+	path, err := stateFilePath()
+	if err != nil {
+		return s
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return &playerState{}
+	}
+	return s
+}
+
+// saveState 把当前状态写回状态文件，调用方需持有 stateMu
+func saveState() error {
+	path, err := stateFilePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,353 @@
+package player
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"music-cli/codec"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/faiface/beep"
+	"golang.org/x/term"
+)
+
+// 可视化模式，按 v 依次切换
+const (
+	vizOff      = "off"
+	vizSpectrum = "spectrum"
+	vizMeter    = "meter"
+	vizBoth     = "both"
+)
+
+var vizModes = []string{vizOff, vizSpectrum, vizMeter, vizBoth}
+
+const (
+	tapSize        = 4096                  // 分流阶段保留的最近采样数
+	fftSize        = 2048                  // 频谱分析的窗口长度，必须是 2 的幂
+	vizFrame       = 40 * time.Millisecond // 重绘间隔
+	vizStale       = 150 * time.Millisecond
+	vizMeterWindow = 50 * time.Millisecond // 电平表计算峰值和 RMS 的窗口
+	vizMinFreq     = 40.0
+	vizMaxFreq     = 16000.0
+	vizFloorDB     = -70.0 // 频谱的下限
+	vizMeterDB     = -60.0 // 电平表的下限
+	vizMaxBars     = 64
+	vizMaxHeight   = 8
+	vizFalloff     = 0.06 // 每帧最多下降的比例，柱子回落得平滑一些
+)
+
+var (
+	vizBlocks = []rune(" ▁▂▃▄▅▆▇█")
+	vizEighth = []rune(" ▏▎▍▌▋▊▉█")
+)
+
+// tap 是效果链末端的分流阶段，原样输出并保留最近的采样给可视化使用
+type tap struct {
+	streamer   beep.Streamer
+	sampleRate beep.SampleRate
+
+	mu      sync.Mutex
+	ring    [tapSize][2]float64
+	pos     int
+	updated time.Time
+}
+
+func newTap(s beep.Streamer, sr beep.SampleRate) *tap {
+	return &tap{streamer: s, sampleRate: sr}
+}
+
+func (t *tap) Stream(samples [][2]float64) (n int, ok bool) {
+	n, ok = t.streamer.Stream(samples)
+	if n == 0 {
+		return n, ok
+	}
+	t.mu.Lock()
+	for _, s := range samples[:n] {
+		t.ring[t.pos] = s
+		t.pos = (t.pos + 1) % tapSize
+	}
+	t.updated = time.Now()
+	t.mu.Unlock()
+	return n, ok
+}
+
+func (t *tap) Err() error {
+	return t.streamer.Err()
+}
+
+// snapshot 把最近的 len(dst) 个采样复制到 dst，暂停或播完后很久没有新采样时返回 false
+func (t *tap) snapshot(dst [][2]float64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Since(t.updated) > vizStale {
+		return false
+	}
+	start := t.pos - len(dst) + tapSize
+	for i := range dst {
+		dst[i] = t.ring[(start+i)%tapSize]
+	}
+	return true
+}
+
+func currentVizMode() string {
+	lockState()
+	defer stateMu.Unlock()
+	for _, mode := range vizModes {
+		if state.Visualizer == mode {
+			return mode
+		}
+	}
+	return vizOff
+}
+
+func cycleVizMode() {
+	mode := currentVizMode()
+	lockState()
+	defer stateMu.Unlock()
+	for i, m := range vizModes {
+		if m == mode {
+			state.Visualizer = vizModes[(i+1)%len(vizModes)]
+			break
+		}
+	}
+	_ = saveState()
+}
+
+// visualizer 在进度条下方、面板所在的位置绘制频谱和电平表，面板打开时让出位置
+type visualizer struct {
+	samples [][2]float64
+	window  []float64
+	fft     []complex128
+	bars    []float64 // 每根柱子当前的高度（0 - 1）
+	rms     [2]float64
+	peaks   [2]float64 // 电平表的峰值保持（0 - 1）
+	height  int        // 上一次绘制的行数
+}
+
+func newVisualizer() *visualizer {
+	v := &visualizer{
+		samples: make([][2]float64, fftSize),
+		window:  make([]float64, fftSize),
+		fft:     make([]complex128, fftSize),
+	}
+	// Hann 窗
+	for i := range v.window {
+		v.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(fftSize-1))
+	}
+	return v
+}
+
+func (p *Player) printVisualizer(wg *sync.WaitGroup, done chan struct{}) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(vizFrame)
+	defer ticker.Stop()
+	v := newVisualizer()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			mode := currentVizMode()
+			panelMu.Lock()
+			panelOpen := activePanel != nil
+			panelMu.Unlock()
+
+			var lines []string
+			if mode != vizOff && !panelOpen {
+				p.mu.Lock()
+				t := p.tap
+				p.mu.Unlock()
+				lines = v.render(t, mode)
+			}
+
+			printMu.Lock()
+			for i, line := range lines {
+				fmt.Printf("\033[%d;1H\033[2K%s\x1b[0m", panelRow+i, line)
+			}
+			if len(lines) < v.height {
+				for i := len(lines); i < v.height; i++ {
+					fmt.Printf("\033[%d;1H\033[2K", panelRow+i)
+				}
+				// 清掉的行可能和面板重叠，让面板重画一次
+				redrawPanel()
+			}
+			v.height = len(lines)
+			printMu.Unlock()
+		}
+	}
+}
+
+// render 返回当前帧要显示的行，终端太矮时只显示放得下的部分
+func (v *visualizer) render(t *tap, mode string) []string {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	rows := height - panelRow
+	if rows <= 0 {
+		return nil
+	}
+
+	live := t != nil && t.snapshot(v.samples)
+	if !live {
+		clear(v.samples)
+	}
+	sr := beep.SampleRate(44100)
+	if t != nil {
+		sr = t.sampleRate
+	}
+
+	var lines []string
+	showMeter := (mode == vizMeter || mode == vizBoth) && rows >= 2
+	if mode == vizSpectrum || mode == vizBoth {
+		spectrumRows := rows
+		if showMeter {
+			spectrumRows -= 3 // 电平表两行，中间空一行
+		}
+		if spectrumRows >= 2 {
+			lines = append(lines, v.spectrum(sr, width, min(spectrumRows, vizMaxHeight))...)
+			if showMeter {
+				lines = append(lines, "")
+			}
+		}
+	}
+	if showMeter {
+		lines = append(lines, v.meter(sr, width)...)
+	}
+	return lines
+}
+
+// spectrum 对最近的采样做 FFT，按对数频率分成若干根柱子
+func (v *visualizer) spectrum(sr beep.SampleRate, width, height int) []string {
+	count := min(vizMaxBars, (width-4)/2)
+	if count <= 0 {
+		return nil
+	}
+	if len(v.bars) != count {
+		v.bars = make([]float64, count)
+	}
+
+	for i, s := range v.samples {
+		v.fft[i] = complex((s[0]+s[1])/2*v.window[i], 0)
+	}
+	codec.FFT(v.fft)
+
+	maxFreq := min(vizMaxFreq, float64(sr)/2)
+	binWidth := float64(sr) / fftSize
+	// Hann 窗的增益是 0.5，满幅正弦的幅度约为 fftSize/4
+	scale := 4.0 / fftSize
+	for b := range v.bars {
+		lo := vizMinFreq * math.Pow(maxFreq/vizMinFreq, float64(b)/float64(count))
+		hi := vizMinFreq * math.Pow(maxFreq/vizMinFreq, float64(b+1)/float64(count))
+		first := int(lo / binWidth)
+		last := max(first, int(hi/binWidth))
+		magnitude := 0.0
+		for k := first; k <= last && k < fftSize/2; k++ {
+			magnitude = max(magnitude, cmplx.Abs(v.fft[k]))
+		}
+		level := 0.0
+		if magnitude > 0 {
+			level = (20*math.Log10(magnitude*scale) - vizFloorDB) / -vizFloorDB
+		}
+		level = max(0, min(1, level))
+		v.bars[b] = max(level, v.bars[b]-vizFalloff)
+	}
+
+	lines := make([]string, height)
+	for row := range lines {
+		fromBottom := height - 1 - row
+		var sb strings.Builder
+		sb.WriteString("  ")
+		sb.WriteString(vizColor(float64(fromBottom+1) / float64(height)))
+		for _, level := range v.bars {
+			cells := int(level*float64(height*8)) - fromBottom*8
+			sb.WriteRune(vizBlocks[max(0, min(8, cells))])
+			sb.WriteByte(' ')
+		}
+		lines[row] = sb.String()
+	}
+	return lines
+}
+
+// meter 显示左右声道的 RMS 电平条、峰值保持标记和峰值读数
+func (v *visualizer) meter(sr beep.SampleRate, width int) []string {
+	window := v.samples[len(v.samples)-min(len(v.samples), sr.N(vizMeterWindow)):]
+	length := width - 16
+	if length <= 0 {
+		return nil
+	}
+
+	lines := make([]string, 2)
+	for c, name := range []string{"L", "R"} {
+		peak, sum := 0.0, 0.0
+		for _, s := range window {
+			peak = max(peak, math.Abs(s[c]))
+			sum += s[c] * s[c]
+		}
+		rms := math.Sqrt(sum / float64(len(window)))
+		v.rms[c] = max(meterLevel(rms), v.rms[c]-vizFalloff)
+		v.peaks[c] = max(meterLevel(peak), v.peaks[c]-vizFalloff/3)
+
+		var sb strings.Builder
+		sb.WriteString("  " + name + " ")
+		cells := v.rms[c] * float64(length)
+		marker := min(length-1, int(v.peaks[c]*float64(length)))
+		for i := 0; i < length; i++ {
+			switch {
+			case i == marker && v.peaks[c] > 0:
+				sb.WriteString("\x1b[37;1m│")
+			case float64(i+1) <= cells:
+				sb.WriteString(vizColor(float64(i+1) / float64(length)))
+				sb.WriteRune('█')
+			case float64(i) < cells:
+				sb.WriteString(vizColor(float64(i+1) / float64(length)))
+				sb.WriteRune(vizEighth[int((cells-float64(i))*8)])
+			default:
+				sb.WriteString("\x1b[30;1m·")
+			}
+		}
+		db := vizMeterDB
+		if peak > 0 {
+			db = max(vizMeterDB, 20*math.Log10(peak))
+		}
+		sb.WriteString(fmt.Sprintf("\x1b[0m %6.1fdB", db))
+		lines[c] = sb.String()
+	}
+	return lines
+}
+
+// meterLevel 把振幅换算成电平表上的位置（0 - 1）
+func meterLevel(amplitude float64) float64 {
+	if amplitude <= 0 {
+		return 0
+	}
+	return max(0, min(1, (20*math.Log10(amplitude)-vizMeterDB)/-vizMeterDB))
+}
+
+// vizColor 低处绿色，接近满幅时黄色和红色
+func vizColor(level float64) string {
+	switch {
+	case level > 0.95:
+		return "\x1b[31m"
+	case level > 0.8:
+		return "\x1b[33m"
+	}
+	return "\x1b[32m"
+}
+
+func vizText() string {
+	switch currentVizMode() {
+	case vizSpectrum:
+		return "频谱"
+	case vizMeter:
+		return "电平表"
+	case vizBoth:
+		return "频谱+电平表"
+	}
+	return "关"
+}
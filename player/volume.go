@@ -0,0 +1,91 @@
+package player
+
+import (
+	"fmt"
+
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/effects"
+)
+
+// 音量以 dB 为单位调节，effects.Volume 的 Base 取 10、Volume 取 dB/20 即为对应的振幅增益
+const (
+	volumeStep = 2.0
+	minVolume  = -40.0
+	maxVolume  = 6.0
+	volumeBase = 10
+)
+
+// newVolume 按当前保存的音量创建 Volume 阶段，睡眠定时淡出时叠加淡出的衰减
+func newVolume(s beep.Streamer) *effects.Volume {
+	fade := sleepFade()
+	lockState()
+	defer stateMu.Unlock()
+	return &effects.Volume{
+		Streamer: s,
+		Base:     volumeBase,
+		Volume:   (state.Volume + fade) / 20,
+		Silent:   state.Muted,
+	}
+}
+
+// changeVolume 调整全局音量并立即应用到正在播放和已排队的曲目
+func changeVolume(delta float64, players ...*Player) {
+	lockState()
+	state.Volume += delta
+	if state.Volume < minVolume {
+		state.Volume = minVolume
+	}
+	if state.Volume > maxVolume {
+		state.Volume = maxVolume
+	}
+	state.Muted = false
+	_ = saveState()
+	stateMu.Unlock()
+
+	for _, p := range players {
+		p.applyVolume()
+	}
+}
+
+// toggleMute 切换静音，静音不会改变记录的音量
+func toggleMute(players ...*Player) {
+	lockState()
+	state.Muted = !state.Muted
+	_ = saveState()
+	stateMu.Unlock()
+
+	for _, p := range players {
+		p.applyVolume()
+	}
+}
+
+// applyVolume 把全局音量同步到当前曲目的 Volume 阶段
+func (p *Player) applyVolume() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.volume == nil {
+		return
+	}
+	lockState()
+	volume, muted := state.Volume, state.Muted
+	stateMu.Unlock()
+	volume += sleepFade()
+
+	output.Lock()
+	p.volume.Volume = volume / 20
+	p.volume.Silent = muted
+	output.Unlock()
+}
+
+func volumeText() string {
+	lockState()
+	defer stateMu.Unlock()
+	if state.Muted {
+		return "静音"
+	}
+	return fmt.Sprintf("音量 %+.0fdB", state.Volume)
+}
@@ -0,0 +1,196 @@
+package player
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"music-cli/codec"
+	"music-cli/utils"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// 波形按整首曲目分成固定数量的段，绘制时再缩放到进度条的长度
+	waveformBuckets = 1024
+	waveformDir     = "waveforms"
+	waveformChunk   = 8192
+	// waveformHashSpan 是计算缓存键时从文件开头和结尾各读的字节数
+	waveformHashSpan = 1 << 20
+)
+
+// waveformLevels 是从低到高的波形字符，最低一级也画出来，安静的段落不会断开
+var waveformLevels = []rune("▁▂▃▄▅▆▇█")
+
+// waveform 是每一段的 RMS 电平，已经归一化到 0 - 255
+// 母带处理过的音乐峰值几乎处处顶格，用 RMS 才能看出段落的起伏
+type waveform []byte
+
+var errWaveformCanceled = errors.New("waveform canceled")
+
+// loadWaveform 在后台读取或计算波形，完成后交给进度条，done 关闭时放弃计算
+func (p *Player) loadWaveform(pb *progressBar, done chan struct{}) {
+	p.mu.Lock()
+	path, cue := p.audioPath(), p.cue
+	p.mu.Unlock()
+
+	key, err := waveformKey(path, cue)
+	if err != nil {
+		return
+	}
+	if w, err := readWaveform(key); err == nil {
+		pb.wave.Store(&w)
+		return
+	}
+	w, err := computeWaveform(path, cue, done)
+	if err != nil {
+		return
+	}
+	_ = writeWaveform(key, w)
+	pb.wave.Store(&w)
+}
+
+// waveformKey 按文件内容的哈希识别文件，移动、改名、复制之后还能命中缓存
+// 大文件只哈希开头和结尾各 waveformHashSpan 字节再加上文件大小，不用把整个文件读一遍
+// CUE 音轨再加上音轨号和起止位置
+func waveformKey(path string, cue *utils.CueTrack) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := info.Size()
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00", size)
+	if size <= 2*waveformHashSpan {
+		_, err = io.Copy(h, f)
+	} else {
+		_, err = io.Copy(h, io.NewSectionReader(f, 0, waveformHashSpan))
+		if err == nil {
+			_, err = io.Copy(h, io.NewSectionReader(f, size-waveformHashSpan, waveformHashSpan))
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if cue != nil {
+		fmt.Fprintf(h, "\x00%d\x00%d\x00%d", cue.Number, cue.Start, cue.End)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func waveformPath(key string) (string, error) {
+	dir, err := utils.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, waveformDir, key+".wave"), nil
+}
+
+func readWaveform(key string) (waveform, error) {
+	path, err := waveformPath(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != waveformBuckets {
+		return nil, fmt.Errorf("invalid waveform cache: %s", path)
+	}
+	return data, nil
+}
+
+func writeWaveform(key string, w waveform) error {
+	path, err := waveformPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, w, 0o644)
+}
+
+// computeWaveform 用单独的解码器把整首曲目读一遍，不影响正在播放的解码器
+func computeWaveform(path string, cue *utils.CueTrack, done chan struct{}) (waveform, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	streamer, format, err := codec.Decode(path, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	defer streamer.Close()
+	if cue != nil {
+		bounded, err := newSection(streamer, format, cue)
+		if err != nil {
+			return nil, err
+		}
+		streamer = bounded
+	}
+
+	total := streamer.Len()
+	if total <= 0 {
+		return nil, errors.New("empty track")
+	}
+	sums := make([]float64, waveformBuckets)
+	counts := make([]int, waveformBuckets)
+	buf := make([][2]float64, waveformChunk)
+	pos := 0
+	for {
+		select {
+		case <-done:
+			return nil, errWaveformCanceled
+		default:
+		}
+		n, ok := streamer.Stream(buf)
+		for i, s := range buf[:n] {
+			bucket := min(waveformBuckets-1, (pos+i)*waveformBuckets/total)
+			sums[bucket] += (s[0]*s[0] + s[1]*s[1]) / 2
+			counts[bucket]++
+		}
+		pos += n
+		if !ok {
+			break
+		}
+	}
+
+	// 按最响的一段归一化，整体很轻的曲目也能看出起伏
+	levels := make([]float64, waveformBuckets)
+	loudest := 0.0
+	for i, sum := range sums {
+		if counts[i] > 0 {
+			levels[i] = math.Sqrt(sum / float64(counts[i]))
+		}
+		loudest = max(loudest, levels[i])
+	}
+	w := make(waveform, waveformBuckets)
+	if loudest > 0 {
+		for i, level := range levels {
+			w[i] = byte(math.Round(level / loudest * 255))
+		}
+	}
+	return w, nil
+}
+
+// cell 返回进度条第 i 格（共 length 格）对应的波形字符，取这一格覆盖的各段中的最大值
+func (w waveform) cell(i, length int) rune {
+	first := i * len(w) / length
+	last := max(first+1, (i+1)*len(w)/length)
+	level := byte(0)
+	for _, v := range w[first:min(last, len(w))] {
+		level = max(level, v)
+	}
+	return waveformLevels[int(level)*(len(waveformLevels)-1)/255]
+}
@@ -0,0 +1,85 @@
+package player
+
+import (
+	"music-cli/utils"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWaveformKey(t *testing.T) {
+	path := writeTone(t, "a.wav", time.Second, [2]float64{0.5, 0.5})
+	same := writeTone(t, "b.wav", time.Second, [2]float64{0.5, 0.5})
+	louder := writeTone(t, "c.wav", time.Second, [2]float64{0.6, 0.6})
+	key := func(path string, cue *utils.CueTrack) string {
+		t.Helper()
+		k, err := waveformKey(path, cue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	first := key(path, nil)
+	// 缓存跟着内容走：复制、改名或者只改了修改时间都还能命中
+	if key(same, nil) != first {
+		t.Error("files with the same content have different keys")
+	}
+	renamed := filepath.Join(t.TempDir(), "renamed.wav")
+	if err := os.Rename(same, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if key(renamed, nil) != first {
+		t.Error("key changed after a rename")
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if key(path, nil) != first {
+		t.Error("key changed with only the modification time")
+	}
+	if key(louder, nil) == first {
+		t.Error("files with different content share a key")
+	}
+
+	track1 := &utils.CueTrack{Number: 1, File: path, End: time.Second / 2}
+	track2 := &utils.CueTrack{Number: 2, File: path, Start: time.Second / 2}
+	if k1, k2 := key(path, track1), key(path, track2); k1 == first || k1 == k2 {
+		t.Error("cue tracks of the same file share a key")
+	}
+	moved := *track1
+	moved.End = time.Second / 4
+	if key(path, &moved) == key(path, track1) {
+		t.Error("key did not change when the cue sheet moved the track end")
+	}
+
+	// 大文件只读开头和结尾，两头的改动都要反映在键里
+	big := make([]byte, 3*waveformHashSpan)
+	large := filepath.Join(t.TempDir(), "large.wav")
+	if err := os.WriteFile(large, big, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	base := key(large, nil)
+	for _, at := range []int{0, len(big) - 1} {
+		big[at] = 1
+		if err := os.WriteFile(large, big, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if key(large, nil) == base {
+			t.Errorf("key did not change when byte %d changed", at)
+		}
+		big[at] = 0
+	}
+	if err := os.WriteFile(large, big[:len(big)-1], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if key(large, nil) == base {
+		t.Error("key did not change with the file size")
+	}
+
+	if _, err := waveformKey(path+".missing", nil); err == nil {
+		t.Error("missing file: expected an error")
+	}
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"music-cli/codec"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const analysisFileName = "analysis.json"
+
+// analysisEntry 是缓存的速度和调性，文件被修改后失效
+type analysisEntry struct {
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	BPM     float64   `json:"bpm"`
+	Key     codec.Key `json:"key"` // 0 - 11 大调，12 - 23 小调，-1 未知
+}
+
+var (
+	analysisMu     sync.Mutex
+	analysisCache  map[string]analysisEntry // 绝对路径 -> 结果，第一次用到时从文件读取
+	analysisLoaded bool
+	analysisDirty  = make(map[string]bool) // 还没有写回文件的条目
+)
+
+func analysisFilePath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, analysisFileName), nil
+}
+
+// readAnalysisFile 读取缓存文件，文件不存在或损坏时返回空表
+func readAnalysisFile() map[string]analysisEntry {
+	entries := make(map[string]analysisEntry)
+	path, err := analysisFilePath()
+	if err != nil {
+		return entries
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return entries
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return make(map[string]analysisEntry)
+	}
+	return entries
+}
+
+// analysisKey 返回缓存使用的绝对路径和用来判断文件是否被修改的信息，CUE 音轨检查它引用的音频文件
+func analysisKey(path string) (string, os.FileInfo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, err
+	}
+	file := path
+	if _, _, ok := SplitCueTrackPath(path); ok {
+		track, err := LoadCueTrack(path)
+		if err != nil {
+			return "", nil, err
+		}
+		file = track.File
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", nil, err
+	}
+	return abs, info, nil
+}
+
+// LookupAnalysis 返回缓存中的速度和调性，没有分析过或文件已经改变时返回 false
+func LookupAnalysis(path string) (codec.Analysis, bool) {
+	key, info, err := analysisKey(path)
+	if err != nil {
+		return codec.Analysis{}, false
+	}
+	analysisMu.Lock()
+	defer analysisMu.Unlock()
+	if !analysisLoaded {
+		analysisCache = readAnalysisFile()
+		analysisLoaded = true
+	}
+	entry, ok := analysisCache[key]
+	if !ok || !entry.ModTime.Equal(info.ModTime()) || entry.Size != info.Size() {
+		return codec.Analysis{}, false
+	}
+	return codec.Analysis{BPM: entry.BPM, Key: entry.Key}, true
+}
+
+// StoreAnalysis 把结果放进缓存，调用 SaveAnalysis 后才写入文件
+func StoreAnalysis(path string, a codec.Analysis) error {
+	key, info, err := analysisKey(path)
+	if err != nil {
+		return err
+	}
+	analysisMu.Lock()
+	defer analysisMu.Unlock()
+	if !analysisLoaded {
+		analysisCache = readAnalysisFile()
+		analysisLoaded = true
+	}
+	analysisCache[key] = analysisEntry{ModTime: info.ModTime(), Size: info.Size(), BPM: a.BPM, Key: a.Key}
+	analysisDirty[key] = true
+	return nil
+}
+
+// SaveAnalysis 把新增的结果写回缓存文件
+// 先重新读取文件再合并，另一个进程（例如同时运行的 analyze 子命令）写入的结果不会被覆盖
+func SaveAnalysis() error {
+	analysisMu.Lock()
+	defer analysisMu.Unlock()
+	if len(analysisDirty) == 0 {
+		return nil
+	}
+	path, err := analysisFilePath()
+	if err != nil {
+		return err
+	}
+	entries := readAnalysisFile()
+	for key := range analysisDirty {
+		entries[key] = analysisCache[key]
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	analysisCache = entries
+	clear(analysisDirty)
+	return nil
+}
+
+// FormatAnalysis 把速度和调性格式化成 “128 BPM  Am (8A)”，BPM 有小数时保留一位，未知的部分省略
+func FormatAnalysis(a codec.Analysis) string {
+	text := ""
+	if a.BPM > 0 {
+		text = strconv.FormatFloat(a.BPM, 'f', -1, 64) + " BPM"
+	}
+	if key := a.Key.String(); key != "" {
+		if text != "" {
+			text += "  "
+		}
+		text += fmt.Sprintf("%s (%s)", key, a.Key.Camelot())
+	}
+	return text
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ConfigDirEnv 指定配置目录的环境变量，测试和自动化运行时用来避开用户自己的配置
+const ConfigDirEnv = "MUSIC_CLI_CONFIG_DIR"
+
+// ConfigDir 返回 music-cli 的配置目录，不存在时自动创建
+// 设置了 MUSIC_CLI_CONFIG_DIR 时使用它，否则是系统用户配置目录下的 music-cli；每次调用时重新读取环境变量
+func ConfigDir() (string, error) {
+	dir := os.Getenv(ConfigDirEnv)
+	if dir == "" {
+		base, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(base, "music-cli")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigDirOverride(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "config")
+	t.Setenv(ConfigDirEnv, dir)
+	got, err := ConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != dir {
+		t.Errorf("ConfigDir() = %q, want %q", got, dir)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("config directory was not created: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cueTrackSep 分隔 CUE 文件路径和音轨号，虚拟音轨的路径形如 album.cue#3
+const cueTrackSep = "#"
+
+// CueSheet 是解析后的 CUE 文件
+type CueSheet struct {
+	Path      string
+	Title     string
+	Performer string
+	Tracks    []CueTrack
+}
+
+// CueTrack 是 CUE 文件中的一条音轨，对应音频文件中 [Start, End) 的一段
+type CueTrack struct {
+	Number    int
+	Title     string
+	Performer string
+	Album     string
+	File      string        // 音频文件的完整路径
+	Start     time.Duration // INDEX 01 的位置
+	End       time.Duration // 下一条音轨的 INDEX 01，0 表示一直到文件结尾
+}
+
+// IsCue 判断是否是 CUE 文件
+func IsCue(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".cue")
+}
+
+// CueTrackPath 返回 CUE 音轨的虚拟路径
+func CueTrackPath(cuePath string, number int) string {
+	return cuePath + cueTrackSep + strconv.Itoa(number)
+}
+
+// SplitCueTrackPath 拆分 CUE 音轨的虚拟路径，不是虚拟路径时返回 false
+func SplitCueTrackPath(path string) (string, int, bool) {
+	i := strings.LastIndex(path, cueTrackSep)
+	if i < 0 || !IsCue(path[:i]) {
+		return "", 0, false
+	}
+	number, err := strconv.Atoi(path[i+len(cueTrackSep):])
+	if err != nil {
+		return "", 0, false
+	}
+	return path[:i], number, true
+}
+
+// LoadCueTrack 读取虚拟路径对应的音轨
+func LoadCueTrack(path string) (*CueTrack, error) {
+	cuePath, number, ok := SplitCueTrackPath(path)
+	if !ok {
+		return nil, fmt.Errorf("not a cue track: %s", path)
+	}
+	sheet, err := ParseCue(cuePath)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sheet.Tracks {
+		if sheet.Tracks[i].Number == number {
+			return &sheet.Tracks[i], nil
+		}
+	}
+	return nil, fmt.Errorf("track %d not found in %s", number, cuePath)
+}
+
+// TrackName 返回用于目录列表显示的名字，CUE 音轨显示为 “音轨号. 艺术家 - 标题”
+func TrackName(path string) string {
+	if _, _, ok := SplitCueTrackPath(path); ok {
+		if track, err := LoadCueTrack(path); err == nil {
+			return fmt.Sprintf("%02d. %s - %s", track.Number, track.Performer, track.Title)
+		}
+	}
+	return filepath.Base(path)
+}
+
+// expandCueSheets 把文件列表中的 CUE 文件展开成虚拟音轨，并去掉已经被 CUE 文件引用的整轨音频
+// 引用的音频文件不存在的 CUE 文件会被忽略
+func expandCueSheets(paths []string) []string {
+	covered := make(map[string]bool)
+	expanded := make(map[string][]string)
+	for _, path := range paths {
+		if !IsCue(path) {
+			continue
+		}
+		sheet, err := ParseCue(path)
+		if err != nil || len(sheet.Tracks) == 0 {
+			continue
+		}
+		var tracks []string
+		missing := false
+		for _, t := range sheet.Tracks {
+			if _, err := os.Stat(t.File); err != nil {
+				missing = true
+				break
+			}
+			tracks = append(tracks, CueTrackPath(path, t.Number))
+		}
+		if missing {
+			continue
+		}
+		for _, t := range sheet.Tracks {
+			covered[filepath.Clean(t.File)] = true
+		}
+		expanded[path] = tracks
+	}
+
+	var files []string
+	for _, path := range paths {
+		switch {
+		case IsCue(path):
+			files = append(files, expanded[path]...)
+		case !covered[filepath.Clean(path)]:
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// ParseCue 解析 CUE 文件，支持 UTF-8（可带 BOM）和 GBK 编码
+func ParseCue(path string) (*CueSheet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = decodeText(data)
+
+	sheet := &CueSheet{Path: path}
+	dir := filepath.Dir(path)
+	var file string
+	var track *CueTrack
+	inTrack := false // 第一个 TRACK 之后的 TITLE、PERFORMER 都属于音轨，跳过的音轨也一样
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := splitCueLine(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "FILE":
+			if len(fields) > 1 {
+				file = fields[1]
+				if !filepath.IsAbs(file) {
+					file = filepath.Join(dir, file)
+				}
+			}
+		case "TRACK":
+			inTrack = true
+			if len(fields) < 3 || !strings.EqualFold(fields[2], "AUDIO") {
+				track = nil
+				continue
+			}
+			number, err := strconv.Atoi(fields[1])
+			if err != nil {
+				track = nil
+				continue
+			}
+			sheet.Tracks = append(sheet.Tracks, CueTrack{Number: number, File: file, Start: -1})
+			track = &sheet.Tracks[len(sheet.Tracks)-1]
+		case "TITLE":
+			if len(fields) < 2 {
+				continue
+			}
+			if track != nil {
+				track.Title = fields[1]
+			} else if !inTrack {
+				sheet.Title = fields[1]
+			}
+		case "PERFORMER":
+			if len(fields) < 2 {
+				continue
+			}
+			if track != nil {
+				track.Performer = fields[1]
+			} else if !inTrack {
+				sheet.Performer = fields[1]
+			}
+		case "INDEX":
+			if track == nil || len(fields) < 3 || fields[1] != "01" {
+				continue
+			}
+			start, err := parseCueTime(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			track.Start = start
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// 去掉没有 INDEX 01 的音轨，补全标题、艺术家和结束位置
+	tracks := sheet.Tracks[:0]
+	for _, t := range sheet.Tracks {
+		if t.Start >= 0 && t.File != "" {
+			tracks = append(tracks, t)
+		}
+	}
+	sheet.Tracks = tracks
+	for i := range sheet.Tracks {
+		t := &sheet.Tracks[i]
+		t.Album = sheet.Title
+		if t.Performer == "" {
+			t.Performer = sheet.Performer
+		}
+		if t.Title == "" {
+			t.Title = fmt.Sprintf("Track %02d", t.Number)
+		}
+		if i+1 < len(sheet.Tracks) && sheet.Tracks[i+1].File == t.File {
+			t.End = sheet.Tracks[i+1].Start
+		}
+	}
+	return sheet, nil
+}
+
+// splitCueLine 按空白拆分一行，双引号中的内容作为一个字段
+func splitCueLine(line string) []string {
+	var fields []string
+	line = strings.TrimSpace(line)
+	for line != "" {
+		if line[0] == '"' {
+			end := strings.Index(line[1:], `"`)
+			if end < 0 {
+				fields = append(fields, line[1:])
+				break
+			}
+			fields = append(fields, line[1:end+1])
+			line = strings.TrimSpace(line[end+2:])
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			fields = append(fields, line)
+			break
+		}
+		fields = append(fields, line[:end])
+		line = strings.TrimSpace(line[end:])
+	}
+	return fields
+}
+
+// parseCueTime 解析 mm:ss:ff 格式的时间，一秒 75 帧
+func parseCueTime(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid cue time %q", s)
+	}
+	var v [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("invalid cue time %q", s)
+		}
+		v[i] = n
+	}
+	return time.Duration(v[0])*time.Minute + time.Duration(v[1])*time.Second + time.Duration(v[2])*time.Second/75, nil
+}
@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func writeCue(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func cueTime(m, s, f int) time.Duration {
+	return time.Duration(m)*time.Minute + time.Duration(s)*time.Second + time.Duration(f)*time.Second/75
+}
+
+func TestParseCue(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "image.flac")
+	tests := []struct {
+		name      string
+		content   string
+		title     string
+		performer string
+		tracks    []CueTrack
+	}{
+		{
+			name: "single file",
+			content: `REM GENRE Rock
+PERFORMER "Band"
+TITLE "Album"
+FILE "image.flac" WAVE
+  TRACK 01 AUDIO
+    TITLE "One"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Two"
+    PERFORMER "Guest"
+    INDEX 00 03:58:10
+    INDEX 01 04:00:37
+  TRACK 03 AUDIO
+    INDEX 01 07:30:74
+`,
+			title: "Album", performer: "Band",
+			tracks: []CueTrack{
+				{Number: 1, Title: "One", Performer: "Band", Album: "Album", File: image, Start: 0, End: cueTime(4, 0, 37)},
+				{Number: 2, Title: "Two", Performer: "Guest", Album: "Album", File: image, Start: cueTime(4, 0, 37), End: cueTime(7, 30, 74)},
+				{Number: 3, Title: "Track 03", Performer: "Band", Album: "Album", File: image, Start: cueTime(7, 30, 74)},
+			},
+		},
+		{
+			// 每条音轨一个文件时，结束位置是文件结尾；FILE 可以不加引号，可以是绝对路径
+			name: "one file per track",
+			content: `FILE a.wav WAVE
+TRACK 1 AUDIO
+INDEX 01 00:00:00
+FILE "sub dir/b.wav" WAVE
+TRACK 2 AUDIO
+INDEX 01 00:00:00
+FILE "/music/c.wav" WAVE
+TRACK 3 AUDIO
+INDEX 01 00:01:00
+`,
+			tracks: []CueTrack{
+				{Number: 1, Title: "Track 01", File: filepath.Join(dir, "a.wav")},
+				{Number: 2, Title: "Track 02", File: filepath.Join(dir, "sub dir", "b.wav")},
+				{Number: 3, Title: "Track 03", File: "/music/c.wav", Start: time.Second},
+			},
+		},
+		{
+			// 下一条音轨的 INDEX 00（间隙）不影响上一条的结束位置，第二个文件从头开始
+			name: "gap across files",
+			content: `FILE "a.wav" WAVE
+  TRACK 01 AUDIO
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    INDEX 01 02:00:00
+FILE "b.wav" WAVE
+    INDEX 00 02:10:00
+  TRACK 03 AUDIO
+    INDEX 01 00:00:00
+`,
+			tracks: []CueTrack{
+				{Number: 1, Title: "Track 01", File: filepath.Join(dir, "a.wav"), End: 2 * time.Minute},
+				{Number: 2, Title: "Track 02", File: filepath.Join(dir, "a.wav"), Start: 2 * time.Minute},
+				{Number: 3, Title: "Track 03", File: filepath.Join(dir, "b.wav")},
+			},
+		},
+		{
+			// 数据轨、没有 INDEX 01 的音轨、FILE 之前的音轨和音轨号不是数字的音轨都被丢掉，
+			// 它们的 TITLE 也不会变成专辑标题
+			name: "skipped tracks",
+			content: `TRACK 09 AUDIO
+  INDEX 01 00:00:00
+FILE "image.flac" WAVE
+  TRACK 01 MODE1/2352
+    TITLE "Data"
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "No index"
+    INDEX 00 01:00:00
+  TRACK 03 AUDIO
+    TITLE "Kept"
+    INDEX 01 02:00:00
+  TRACK xx AUDIO
+    TITLE "Bad number"
+    INDEX 01 03:00:00
+  TRACK 04
+    INDEX 01 04:00:00
+`,
+			tracks: []CueTrack{
+				{Number: 3, Title: "Kept", File: image, Start: 2 * time.Minute},
+			},
+		},
+		{
+			// 小写关键字、CRLF 换行、UTF-8 BOM、未闭合的引号
+			name:    "loose syntax",
+			content: "\ufefftitle \"Album\r\nfile \"image.flac\" wave\r\n\ttrack 01 audio\r\n\t\ttitle Unquoted\r\n\t\tindex 01 00:10:00\r\n",
+			title:   "Album",
+			tracks: []CueTrack{
+				{Number: 1, Title: "Unquoted", Album: "Album", File: image, Start: 10 * time.Second},
+			},
+		},
+		{
+			name:    "empty",
+			content: "REM nothing here\n",
+		},
+	}
+	for _, tt := range tests {
+		path := writeCue(t, dir, "album.cue", tt.content)
+		sheet, err := ParseCue(path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if sheet.Title != tt.title || sheet.Performer != tt.performer {
+			t.Errorf("%s: sheet %q by %q, want %q by %q", tt.name, sheet.Title, sheet.Performer, tt.title, tt.performer)
+		}
+		if len(sheet.Tracks) != len(tt.tracks) || (len(tt.tracks) > 0 && !reflect.DeepEqual(sheet.Tracks, tt.tracks)) {
+			t.Errorf("%s: got tracks\n%+v\nwant\n%+v", tt.name, sheet.Tracks, tt.tracks)
+		}
+	}
+
+	path := writeCue(t, dir, "bad.cue", "FILE \"image.flac\" WAVE\nTRACK 01 AUDIO\nINDEX 01 1:2\n")
+	if _, err := ParseCue(path); err == nil {
+		t.Error("invalid INDEX time: expected an error")
+	}
+}
+
+func TestParseCueGBK(t *testing.T) {
+	content, err := simplifiedchinese.GBK.NewEncoder().String("TITLE \"专辑\"\nFILE \"整轨.flac\" WAVE\nTRACK 01 AUDIO\nTITLE \"第一首\"\nINDEX 01 00:00:00\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	sheet, err := ParseCue(writeCue(t, dir, "album.cue", content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sheet.Title != "专辑" || len(sheet.Tracks) != 1 || sheet.Tracks[0].Title != "第一首" ||
+		sheet.Tracks[0].File != filepath.Join(dir, "整轨.flac") {
+		t.Errorf("got %+v", sheet)
+	}
+}
+
+func TestParseCueTime(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+		ok    bool
+	}{
+		{"00:00:00", 0, true},
+		{"01:02:30", time.Minute + 2*time.Second + 400*time.Millisecond, true},
+		{"00:00:74", 74 * time.Second / 75, true},
+		{"99:59:74", cueTime(99, 59, 74), true},
+		{"01:02", 0, false},
+		{"01:02:03:04", 0, false},
+		{"aa:00:00", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseCueTime(tt.input)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseCueTime(%q) = %v, %v, want %v, ok %v", tt.input, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSplitCueTrackPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		cue    string
+		number int
+		ok     bool
+	}{
+		{"/music/album.cue#3", "/music/album.cue", 3, true},
+		{"/music/Album.CUE#12", "/music/Album.CUE", 12, true},
+		{"/music/#1 hits/album.cue#1", "/music/#1 hits/album.cue", 1, true},
+		{"/music/album.cue", "", 0, false},
+		{"/music/song#1.flac", "", 0, false},
+		{"/music/album.flac#3", "", 0, false},
+		{"/music/album.cue#x", "", 0, false},
+	}
+	for _, tt := range tests {
+		cue, number, ok := SplitCueTrackPath(tt.path)
+		if cue != tt.cue || number != tt.number || ok != tt.ok {
+			t.Errorf("SplitCueTrackPath(%q) = %q, %d, %v, want %q, %d, %v", tt.path, cue, number, ok, tt.cue, tt.number, tt.ok)
+		}
+	}
+	if path := CueTrackPath("/music/album.cue", 7); path != "/music/album.cue#7" {
+		t.Errorf("CueTrackPath = %q", path)
+	}
+}
+
+func TestExpandCueSheets(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"image.flac", "single.flac"} {
+		writeCue(t, dir, name, "")
+	}
+	album := writeCue(t, dir, "album.cue", "FILE \"image.flac\" WAVE\nTRACK 01 AUDIO\nINDEX 01 00:00:00\nTRACK 02 AUDIO\nINDEX 01 01:00:00\n")
+	// 引用的音频文件不存在的 CUE 文件被忽略
+	missing := writeCue(t, dir, "missing.cue", "FILE \"gone.flac\" WAVE\nTRACK 01 AUDIO\nINDEX 01 00:00:00\n")
+	empty := writeCue(t, dir, "empty.cue", "REM\n")
+	image, single := filepath.Join(dir, "image.flac"), filepath.Join(dir, "single.flac")
+
+	got := expandCueSheets([]string{album, empty, image, missing, single})
+	want := []string{album + "#1", album + "#2", single}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"fmt"
+	"music-cli/codec"
+	"os"
+	"sync"
+	"time"
+)
+
+// durationEntry 缓存的时长，文件被修改后重新读取
+type durationEntry struct {
+	modTime  time.Time
+	size     int64
+	duration time.Duration
+}
+
+var durationCache sync.Map // path -> durationEntry
+
+// TrackDuration 返回曲目的时长，CUE 音轨按 INDEX 计算，最后一条音轨用整轨时长减去起点
+func TrackDuration(path string) (time.Duration, bool) {
+	if _, _, ok := SplitCueTrackPath(path); ok {
+		track, err := LoadCueTrack(path)
+		if err != nil {
+			return 0, false
+		}
+		if track.End > 0 {
+			return track.End - track.Start, true
+		}
+		total, ok := fileDuration(track.File)
+		if !ok || total <= track.Start {
+			return 0, false
+		}
+		return total - track.Start, true
+	}
+	return fileDuration(path)
+}
+
+func fileDuration(path string) (time.Duration, bool) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	if v, ok := durationCache.Load(path); ok {
+		entry := v.(durationEntry)
+		if entry.modTime.Equal(stat.ModTime()) && entry.size == stat.Size() {
+			return entry.duration, true
+		}
+	}
+	duration, err := codec.Duration(path)
+	if err != nil {
+		return 0, false
+	}
+	durationCache.Store(path, durationEntry{modTime: stat.ModTime(), size: stat.Size(), duration: duration})
+	return duration, true
+}
+
+// FormatDuration 把时长格式化成 mm:ss，超过一小时时为 h:mm:ss
+func FormatDuration(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
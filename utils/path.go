@@ -3,8 +3,11 @@ package utils
 import (
 	"fmt"
 	"io/fs"
+	"music-cli/codec"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"golang.org/x/term"
 )
@@ -16,7 +19,7 @@ func WalkDir(root string) ([]string, error) {
 		if err != nil {
 			return err // 如果访问路径出错，则停止遍历
 		}
-		if !d.IsDir() && (filepath.Ext(d.Name()) == ".mp3" || filepath.Ext(d.Name()) == ".flac" || filepath.Ext(d.Name()) == ".wav") {
+		if !d.IsDir() && (IsCue(path) || codec.IsSupported(path)) {
 			files = append(files, path)
 		}
 		return nil
@@ -26,7 +29,7 @@ func WalkDir(root string) ([]string, error) {
 		return nil, err
 	}
 
-	return files, nil
+	return expandCueSheets(files), nil
 }
 
 func ListDir(root string) ([]string, []string, error) {
@@ -38,7 +41,7 @@ func ListDir(root string) ([]string, []string, error) {
 		return nil, nil, err
 	}
 	if !info.IsDir() {
-		files = append(files, root)
+		files = expandCueSheets([]string{root})
 	} else {
 		entries, err := os.ReadDir(root)
 		if err != nil {
@@ -47,11 +50,12 @@ func ListDir(root string) ([]string, []string, error) {
 		for _, entry := range entries {
 			if entry.IsDir() {
 				dirs = append(dirs, filepath.Join(root, entry.Name()))
-			} else if filepath.Ext(entry.Name()) == ".mp3" || filepath.Ext(entry.Name()) == ".flac" || filepath.Ext(entry.Name()) == ".wav" {
-				files = append(files, filepath.Join(root, entry.Name()))
+			} else if path := filepath.Join(root, entry.Name()); IsCue(path) || codec.IsSupported(path) {
+				files = append(files, path)
 			}
 
 		}
+		files = expandCueSheets(files)
 	}
 	return files, dirs, nil
 }
@@ -74,8 +78,9 @@ func PrintPathInfo(root string, page int) {
 		fmt.Println("错误:", err)
 		return
 	}
+	playlists, _ := ListPlaylists(root)
 
-	total := len(files) + len(dirs)
+	total := len(files) + len(playlists) + len(dirs)
 	start := (page - 1) * pageSize
 	end := start + pageSize
 	if start >= total {
@@ -93,17 +98,33 @@ func PrintPathInfo(root string, page int) {
 	if fileEnd > len(files) {
 		fileEnd = len(files)
 	}
+	listing := currentListing(root)
 	for i := fileStart; i < fileEnd; i++ {
-		fmt.Printf(" %d. %s\n", i+1, filepath.Base(files[i]))
+		line := fmt.Sprintf(" %d. %s", i+1, listing.name(files[i]))
+		if text := listing.analysis(files[i]); text != "" {
+			line += "  " + text
+		}
+		fmt.Println(line)
+	}
+
+	// 打印播放列表（编号排在歌曲之后、目录之前）
+	if len(playlists) > 0 {
+		fmt.Println("播放列表：")
+		for i, playlist := range playlists {
+			if index := len(files) + i; index >= start && index < end {
+				fmt.Printf(" %d. %s\n", index+1, filepath.Base(playlist))
+			}
+		}
 	}
 
 	// 打印目录（如果在当前页范围内）
 	fmt.Println("目录：")
-	dirStart := start - len(files)
+	offset := len(files) + len(playlists)
+	dirStart := start - offset
 	if dirStart < 0 {
 		dirStart = 0
 	}
-	dirEnd := end - len(files)
+	dirEnd := end - offset
 	if dirEnd < 0 {
 		dirEnd = 0
 	}
@@ -112,7 +133,7 @@ func PrintPathInfo(root string, page int) {
 			dirEnd = len(dirs)
 		}
 		for j := dirStart; j < dirEnd; j++ {
-			fmt.Printf(" %d. %s\n", len(files)+j+1, filepath.Base(dirs[j]))
+			fmt.Printf(" %d. %s\n", offset+j+1, filepath.Base(dirs[j]))
 		}
 	}
 
@@ -120,3 +141,63 @@ func PrintPathInfo(root string, page int) {
 		fmt.Println("...（更多内容省略）")
 	}
 }
+
+// listing 缓存一个目录列表中曲目的显示内容，翻页和重绘时不用再打开文件读时长
+// 目录被修改（增删文件）或者换了目录时重新计算
+type listing struct {
+	root    string
+	modTime time.Time
+	names   map[string]string // 路径 -> 名字和时长
+	texts   map[string]string // 路径 -> 速度和调性，只缓存已经分析过的
+}
+
+var (
+	listingMu   sync.Mutex
+	lastListing *listing
+)
+
+func currentListing(root string) *listing {
+	var modTime time.Time
+	if info, err := os.Stat(root); err == nil {
+		modTime = info.ModTime()
+	}
+	listingMu.Lock()
+	defer listingMu.Unlock()
+	if l := lastListing; l != nil && l.root == root && l.modTime.Equal(modTime) {
+		return l
+	}
+	lastListing = &listing{root: root, modTime: modTime, names: make(map[string]string), texts: make(map[string]string)}
+	return lastListing
+}
+
+// name 返回曲目在列表中显示的名字和时长
+func (l *listing) name(path string) string {
+	listingMu.Lock()
+	defer listingMu.Unlock()
+	if name, ok := l.names[path]; ok {
+		return name
+	}
+	name := TrackName(path)
+	if duration, ok := TrackDuration(path); ok {
+		name += fmt.Sprintf(" [%s]", FormatDuration(duration))
+	}
+	l.names[path] = name
+	return name
+}
+
+// analysis 返回已经分析过的速度和调性，列目录时不做分析
+// 还没分析的曲目可能在播放时分析完，所以只缓存找到的结果
+func (l *listing) analysis(path string) string {
+	listingMu.Lock()
+	defer listingMu.Unlock()
+	if text, ok := l.texts[path]; ok {
+		return text
+	}
+	analysis, ok := LookupAnalysis(path)
+	if !ok {
+		return ""
+	}
+	text := FormatAnalysis(analysis)
+	l.texts[path] = text
+	return text
+}
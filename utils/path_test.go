@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"music-cli/codec"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListingCachesDurations(t *testing.T) {
+	dir := t.TempDir()
+	cue := writeCue(t, dir, "album.cue", "FILE \"image.wav\" WAVE\nTRACK 01 AUDIO\nINDEX 01 00:00:00\nTRACK 02 AUDIO\nINDEX 01 01:00:00\n")
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	track := CueTrackPath(cue, 1)
+	if name := currentListing(dir).name(track); !strings.HasSuffix(name, "[01:00]") {
+		t.Fatalf("got %q", name)
+	}
+
+	// 重绘同一个目录时不重新读取曲目
+	writeCue(t, dir, "album.cue", "FILE \"image.wav\" WAVE\nTRACK 01 AUDIO\nINDEX 01 00:00:00\nTRACK 02 AUDIO\nINDEX 01 02:00:00\n")
+	if err := os.Chtimes(dir, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if name := currentListing(dir).name(track); !strings.HasSuffix(name, "[01:00]") {
+		t.Errorf("redraw recomputed the track: %q", name)
+	}
+
+	// 目录改变后重新计算
+	later := info.ModTime().Add(time.Minute)
+	if err := os.Chtimes(dir, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if name := currentListing(dir).name(track); !strings.HasSuffix(name, "[02:00]") {
+		t.Errorf("changed directory still shows %q", name)
+	}
+}
+
+func TestListingAnalysis(t *testing.T) {
+	t.Setenv(ConfigDirEnv, t.TempDir())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.mp3")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l := currentListing(dir)
+	if text := l.analysis(path); text != "" {
+		t.Fatalf("unanalyzed track shows %q", text)
+	}
+	// 播放时分析完的结果在下一次重绘时显示出来
+	if err := StoreAnalysis(path, codec.Analysis{BPM: 120, Key: 9}); err != nil {
+		t.Fatal(err)
+	}
+	if text := l.analysis(path); text == "" {
+		t.Error("analysis stored after the first redraw is not shown")
+	}
+}
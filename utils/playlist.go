@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlaylistEntry 是播放列表中的一项
+type PlaylistEntry struct {
+	Path     string        // 解析后的完整路径
+	Title    string        // #EXTINF 或 TitleN 中的标题，可能为空
+	Duration time.Duration // #EXTINF 或 LengthN 中的时长，未知时为 0
+}
+
+// IsPlaylist 判断是否是支持的播放列表文件（m3u / m3u8 / pls）
+func IsPlaylist(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u", ".m3u8", ".pls":
+		return true
+	}
+	return false
+}
+
+// ListPlaylists 列出目录中的播放列表文件
+func ListPlaylists(root string) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var playlists []string
+	for _, entry := range entries {
+		if !entry.IsDir() && IsPlaylist(entry.Name()) {
+			playlists = append(playlists, filepath.Join(root, entry.Name()))
+		}
+	}
+	return playlists, nil
+}
+
+// ParsePlaylist 解析 M3U / M3U8 / PLS 播放列表，相对路径相对于播放列表所在目录
+func ParsePlaylist(path string) ([]PlaylistEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = decodeText(data)
+	dir := filepath.Dir(path)
+	if strings.EqualFold(filepath.Ext(path), ".pls") {
+		return parsePLS(data, dir), nil
+	}
+	return parseM3U(data, dir), nil
+}
+
+func parseM3U(data []byte, dir string) []PlaylistEntry {
+	var entries []PlaylistEntry
+	var pending PlaylistEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			// #EXTINF:时长,标题
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			length, title, _ := strings.Cut(info, ",")
+			// 时长后面可能还带有 key="value" 形式的属性
+			length, _, _ = strings.Cut(strings.TrimSpace(length), " ")
+			if seconds, err := strconv.ParseFloat(length, 64); err == nil && seconds > 0 {
+				pending.Duration = time.Duration(seconds * float64(time.Second))
+			}
+			pending.Title = strings.TrimSpace(title)
+		case strings.HasPrefix(line, "#"):
+		default:
+			pending.Path = resolveEntry(line, dir)
+			entries = append(entries, pending)
+			pending = PlaylistEntry{}
+		}
+	}
+	return entries
+}
+
+func parsePLS(data []byte, dir string) []PlaylistEntry {
+	byIndex := make(map[int]*PlaylistEntry)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		var field string
+		for _, prefix := range []string{"file", "title", "length"} {
+			if strings.HasPrefix(key, prefix) {
+				field = prefix
+				break
+			}
+		}
+		if field == "" {
+			continue
+		}
+		index, err := strconv.Atoi(key[len(field):])
+		if err != nil {
+			continue
+		}
+		entry, ok := byIndex[index]
+		if !ok {
+			entry = &PlaylistEntry{}
+			byIndex[index] = entry
+		}
+		value = strings.TrimSpace(value)
+		switch field {
+		case "file":
+			entry.Path = resolveEntry(value, dir)
+		case "title":
+			entry.Title = value
+		case "length":
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				entry.Duration = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	indexes := make([]int, 0, len(byIndex))
+	for index, entry := range byIndex {
+		if entry.Path != "" {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+	entries := make([]PlaylistEntry, 0, len(indexes))
+	for _, index := range indexes {
+		entries = append(entries, *byIndex[index])
+	}
+	return entries
+}
+
+// resolveEntry 把播放列表中的条目转换成本地路径
+// 支持 file:// URL，其他 URL 原样返回（之后会被当作不存在的条目）
+func resolveEntry(entry, dir string) string {
+	if u, err := url.Parse(entry); err == nil && u.Scheme == "file" {
+		entry = u.Path
+		if runtime.GOOS == "windows" {
+			entry = strings.TrimPrefix(entry, "/")
+		}
+	} else if strings.Contains(entry, "://") {
+		return entry
+	}
+	// Windows 下生成的播放列表常用反斜杠
+	if runtime.GOOS != "windows" {
+		entry = strings.ReplaceAll(entry, `\`, "/")
+	}
+	entry = filepath.FromSlash(entry)
+	if !filepath.IsAbs(entry) {
+		entry = filepath.Join(dir, entry)
+	}
+	return filepath.Clean(entry)
+}
+
+// WriteM3U8 把条目写成扩展 M3U（UTF-8）播放列表
+// 条目路径尽量写成相对于播放列表所在目录的路径，无法转换时写绝对路径
+func WriteM3U8(path string, entries []PlaylistEntry) error {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	for _, entry := range entries {
+		seconds := -1 // 时长未知
+		if entry.Duration > 0 {
+			seconds = int(entry.Duration.Round(time.Second) / time.Second)
+		}
+		fmt.Fprintf(&buf, "#EXTINF:%d,%s\n", seconds, entry.Title)
+		location := entry.Path
+		if abs, err := filepath.Abs(location); err == nil {
+			location = abs
+			if rel, err := filepath.Rel(dir, abs); err == nil {
+				location = rel
+			}
+		}
+		buf.WriteString(location + "\n")
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
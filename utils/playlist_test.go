@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseM3U(t *testing.T) {
+	dir := "/music/lists"
+	tests := []struct {
+		name    string
+		content string
+		want    []PlaylistEntry
+	}{
+		{
+			name:    "plain paths",
+			content: "a.mp3\n\nsub/b.flac\n../c.wav\n/abs/d.ogg\n",
+			want: []PlaylistEntry{
+				{Path: "/music/lists/a.mp3"},
+				{Path: "/music/lists/sub/b.flac"},
+				{Path: "/music/c.wav"},
+				{Path: "/abs/d.ogg"},
+			},
+		},
+		{
+			name: "extended",
+			content: "#EXTM3U\r\n#EXTINF:215,Artist - Title\r\na.mp3\r\n" +
+				"#EXTINF:-1,Unknown length\r\nb.mp3\r\n" +
+				"#EXTINF:12.5 tvg-id=\"x\",With, comma\r\nc.mp3\r\n" +
+				"# a comment\r\nd.mp3\r\n",
+			want: []PlaylistEntry{
+				{Path: "/music/lists/a.mp3", Title: "Artist - Title", Duration: 215 * time.Second},
+				{Path: "/music/lists/b.mp3", Title: "Unknown length"},
+				{Path: "/music/lists/c.mp3", Title: "With, comma", Duration: 12500 * time.Millisecond},
+				{Path: "/music/lists/d.mp3"},
+			},
+		},
+		{
+			// Windows 下生成的反斜杠路径、file:// URL 和网络地址
+			name:    "locations",
+			content: "..\\Album\\01 Song.flac\nfile:///music/My%20Song.mp3\nhttp://example.com/stream.mp3\n",
+			want: []PlaylistEntry{
+				{Path: "/music/Album/01 Song.flac"},
+				{Path: "/music/My Song.mp3"},
+				{Path: "http://example.com/stream.mp3"},
+			},
+		},
+		{
+			name:    "no entries",
+			content: "#EXTM3U\n#EXTINF:10,dangling\n",
+		},
+	}
+	for _, tt := range tests {
+		got := parseM3U([]byte(tt.content), dir)
+		if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParsePLS(t *testing.T) {
+	dir := "/music/lists"
+	tests := []struct {
+		name    string
+		content string
+		want    []PlaylistEntry
+	}{
+		{
+			name: "standard",
+			content: "[playlist]\nNumberOfEntries=2\n" +
+				"File1=a.mp3\nTitle1=First\nLength1=180\n" +
+				"File2=../b.mp3\nTitle2=Second\nLength2=-1\nVersion=2\n",
+			want: []PlaylistEntry{
+				{Path: "/music/lists/a.mp3", Title: "First", Duration: 180 * time.Second},
+				{Path: "/music/b.mp3", Title: "Second"},
+			},
+		},
+		{
+			// 按序号排列，键名不区分大小写，没有 File 的序号被忽略
+			name: "out of order",
+			content: "[playlist]\r\nfile10 = c.mp3\r\nTITLE3=No file\r\nFILE2=sub\\b.mp3\r\n" +
+				"file1=file:///abs/a%23b.mp3\r\nLengthX=5\r\nbroken line\r\n",
+			want: []PlaylistEntry{
+				{Path: "/abs/a#b.mp3"},
+				{Path: "/music/lists/sub/b.mp3"},
+				{Path: "/music/lists/c.mp3"},
+			},
+		},
+		{
+			name:    "empty",
+			content: "[playlist]\nNumberOfEntries=0\n",
+		},
+	}
+	for _, tt := range tests {
+		got := parsePLS([]byte(tt.content), dir)
+		if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlaylistRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	entries := []PlaylistEntry{
+		{Path: filepath.Join(dir, "a.mp3"), Title: "Artist - Title", Duration: 215 * time.Second},
+		{Path: filepath.Join(dir, "sub", "b.flac"), Title: "Unknown"},
+		{Path: "/elsewhere/c.wav", Title: "Outside", Duration: 1500 * time.Millisecond},
+	}
+	path := filepath.Join(dir, "lists", "saved.m3u8")
+	if err := os.Mkdir(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteM3U8(path, entries); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一棵目录树下的条目写成相对路径
+	for _, line := range []string{"#EXTM3U", "#EXTINF:215,Artist - Title", "../a.mp3", "#EXTINF:-1,Unknown", "../sub/b.flac", "#EXTINF:2,Outside"} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("saved playlist is missing %q:\n%s", line, data)
+		}
+	}
+
+	got, err := ParsePlaylist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries[2].Duration = 2 * time.Second // 时长按整秒保存
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("got %+v, want %+v", got, entries)
+	}
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// decodeText 把 CUE、M3U 这类文本文件的内容转换成 UTF-8
+// 去掉 UTF-8 BOM，不是合法 UTF-8 时按 GBK 解码（很多老文件是 GBK 编码）
+func decodeText(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return data
+	}
+	if decoded, err := simplifiedchinese.GBK.NewDecoder().Bytes(data); err == nil {
+		return decoded
+	}
+	return data
+}